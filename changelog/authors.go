@@ -0,0 +1,164 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Author is a person credited for a release note.
+type Author struct {
+	// Login is the GitHub username of the author, it is empty if the author
+	// could not be matched to a GitHub user
+	Login string `json:"login,omitempty"`
+
+	// URL is the GitHub URL of the author
+	URL string `json:"url,omitempty"`
+
+	// Name is the name of the author from a Co-authored-by trailer
+	Name string `json:"name,omitempty"`
+}
+
+var (
+	coAuthorRE          = regexp.MustCompile(`(?mi)^[ \t]*co-authored-by:[ \t]*(?P<author>.+)$`)
+	coAuthorNameEmailRE = regexp.MustCompile(`^(?P<name>.*?)\s*<(?P<email>[^>]+)>$`)
+	noreplyEmailRE      = regexp.MustCompile(`(?i)^(?:\d+\+)?(?P<login>[^@]+)@users\.noreply\.github\.com$`)
+)
+
+func authorFromLogin(login string) Author {
+	return Author{
+		Login: login,
+		URL:   fmt.Sprintf("https://github.com/%s", login),
+	}
+}
+
+// parseCoAuthor parses the value of a Co-authored-by trailer, either
+// "Name <email>" or "@login". Emails are matched to logins using the GitHub
+// noreply address format or the known commit author emails.
+func parseCoAuthor(value string, loginsByEmail map[string]Author) (Author, bool) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "@") {
+		login := strings.TrimSpace(strings.TrimPrefix(value, "@"))
+		if login == "" {
+			return Author{}, false
+		}
+		return authorFromLogin(login), true
+	}
+
+	match := coAuthorNameEmailRE.FindStringSubmatch(value)
+	if match == nil {
+		return Author{}, false
+	}
+	name, email := strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+
+	if a, ok := loginsByEmail[strings.ToLower(email)]; ok {
+		a.Name = name
+		return a, true
+	}
+	if m := noreplyEmailRE.FindStringSubmatch(email); m != nil {
+		a := authorFromLogin(m[1])
+		a.Name = name
+		return a, true
+	}
+
+	return Author{Name: name}, name != ""
+}
+
+// coAuthorsFromText returns the authors of the Co-authored-by trailers in the
+// text.
+func coAuthorsFromText(text string, loginsByEmail map[string]Author) []Author {
+	var authors []Author
+	for _, match := range coAuthorRE.FindAllStringSubmatch(text, -1) {
+		if a, ok := parseCoAuthor(match[1], loginsByEmail); ok {
+			authors = append(authors, a)
+		}
+	}
+	return authors
+}
+
+// authorsFromPR returns everyone credited for a PR, starting with the primary
+// author, followed by the co-authors in "Co-authored-by:" lines of the PR body
+// and trailers of its commits, and any additional "Original Author:" lines.
+func authorsFromPR(pr pullRequest, primary Author, patterns []*regexp.Regexp) []Author {
+	loginsByEmail := map[string]Author{}
+	for _, n := range pr.Commits.Nodes {
+		a := n.Commit.Author
+		if a.User == nil || a.Email == "" {
+			continue
+		}
+		loginsByEmail[strings.ToLower(a.Email)] = Author{
+			Login: a.User.Login,
+			URL:   a.User.URL,
+		}
+	}
+
+	candidates := []Author{primary}
+	for _, login := range overrideAuthorsFromPR(pr.Body, patterns) {
+		candidates = append(candidates, authorFromLogin(login))
+	}
+	candidates = append(candidates, coAuthorsFromText(pr.Body, loginsByEmail)...)
+	for _, n := range pr.Commits.Nodes {
+		candidates = append(candidates, coAuthorsFromText(n.Commit.Message, loginsByEmail)...)
+	}
+
+	var authors []Author
+	seen := map[string]bool{}
+	for _, a := range candidates {
+		key := strings.ToLower(a.Login)
+		if key == "" {
+			key = "name:" + strings.ToLower(a.Name)
+		}
+		if key == "name:" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		authors = append(authors, a)
+	}
+	return authors
+}
+
+// isBot returns true if the actor is a GitHub App, has a "[bot]" suffixed
+// login, or is one of the configured bot logins.
+func isBot(typename, login string, botLogins []string) bool {
+	if typename == "Bot" || strings.HasSuffix(login, "[bot]") {
+		return true
+	}
+	for _, b := range botLogins {
+		if strings.EqualFold(b, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstHumanCommitAuthor returns the first commit author of the PR that is a
+// GitHub user and not a bot.
+func firstHumanCommitAuthor(pr pullRequest, botLogins []string) (string, string, bool) {
+	for _, n := range pr.Commits.Nodes {
+		u := n.Commit.Author.User
+		if u == nil || isBot("", u.Login, botLogins) {
+			continue
+		}
+		return u.Login, u.URL, true
+	}
+	return "", "", false
+}
+
+// reviewersFromPR returns the users who approved the PR, in the order of
+// their first approval.
+func reviewersFromPR(pr pullRequest) []Author {
+	var reviewers []Author
+	seen := map[string]bool{}
+	for _, r := range pr.Reviews.Nodes {
+		login := r.Author.Login
+		if login == "" || seen[strings.ToLower(login)] {
+			continue
+		}
+		seen[strings.ToLower(login)] = true
+		reviewers = append(reviewers, Author{
+			Login: login,
+			URL:   r.Author.URL,
+		})
+	}
+	return reviewers
+}
@@ -0,0 +1,99 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCoAuthor(t *testing.T) {
+	loginsByEmail := map[string]Author{
+		"foo@example.com": {Login: "foo", URL: "https://github.com/foo"},
+	}
+
+	for i, c := range []struct {
+		expected Author
+		ok       bool
+		value    string
+	}{
+		{Author{}, false, ""},
+		{Author{}, false, "@"},
+		{Author{}, false, "not an author"},
+
+		{Author{Login: "bar", URL: "https://github.com/bar"}, true, "@bar"},
+		{Author{Login: "foo", URL: "https://github.com/foo", Name: "Foo"}, true, "Foo <FOO@example.com>"},
+		{Author{Login: "bar", URL: "https://github.com/bar", Name: "Bar"}, true, "Bar <12345+bar@users.noreply.github.com>"},
+		{Author{Login: "bar", URL: "https://github.com/bar", Name: "Bar"}, true, "Bar <bar@users.noreply.github.com>"},
+		{Author{Name: "Baz Qux"}, true, "Baz Qux <baz@example.com>"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.value), func(t *testing.T) {
+			actual, ok := parseCoAuthor(c.value, loginsByEmail)
+			assert.Equal(t, c.ok, ok)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestAuthorsFromPR(t *testing.T) {
+	var pr pullRequest
+	pr.Body = "Original Author: @foo\nOriginal Author: @bar\n\nCo-authored-by: @baz"
+	pr.Commits.Nodes = make([]pullRequestCommit, 2)
+	pr.Commits.Nodes[0].Commit.Message = "first\n\nCo-authored-by: Qux <qux@example.com>\nCo-authored-by: Foo <foo@users.noreply.github.com>"
+	pr.Commits.Nodes[1].Commit.Message = "second"
+	pr.Commits.Nodes[1].Commit.Author = gitActor{
+		Email: "qux@example.com",
+		User:  &user{Login: "qux", URL: "https://github.com/qux"},
+	}
+
+	actual := authorsFromPR(pr, authorFromLogin("foo"), authorInBodyREs)
+	assert.Equal(t, []Author{
+		{Login: "foo", URL: "https://github.com/foo"},
+		{Login: "bar", URL: "https://github.com/bar"},
+		{Login: "baz", URL: "https://github.com/baz"},
+		{Login: "qux", URL: "https://github.com/qux", Name: "Qux"},
+	}, actual)
+}
+
+func TestFirstHumanCommitAuthor(t *testing.T) {
+	var pr pullRequest
+	pr.Commits.Nodes = make([]pullRequestCommit, 4)
+	pr.Commits.Nodes[1].Commit.Author.User = &user{Login: "dependabot[bot]"}
+	pr.Commits.Nodes[2].Commit.Author.User = &user{Login: "sync-bot"}
+	pr.Commits.Nodes[3].Commit.Author.User = &user{Login: "foo", URL: "https://github.com/foo"}
+
+	login, url, ok := firstHumanCommitAuthor(pr, []string{"Sync-Bot"})
+	assert.True(t, ok)
+	assert.Equal(t, "foo", login)
+	assert.Equal(t, "https://github.com/foo", url)
+
+	_, _, ok = firstHumanCommitAuthor(pr, []string{"foo", "sync-bot"})
+	assert.False(t, ok)
+}
+
+func TestReviewersFromPR(t *testing.T) {
+	var pr pullRequest
+	for _, login := range []string{"foo", "", "bar", "Foo"} {
+		var r struct {
+			Author struct {
+				Login string
+				URL   string
+			}
+		}
+		r.Author.Login = login
+		r.Author.URL = "https://github.com/" + login
+		pr.Reviews.Nodes = append(pr.Reviews.Nodes, r)
+	}
+
+	assert.Equal(t, []Author{
+		{Login: "foo", URL: "https://github.com/foo"},
+		{Login: "bar", URL: "https://github.com/bar"},
+	}, reviewersFromPR(pr))
+}
+
+func TestIsBot(t *testing.T) {
+	assert.True(t, isBot("Bot", "dependabot", nil))
+	assert.True(t, isBot("User", "renovate[bot]", nil))
+	assert.True(t, isBot("User", "Sync-Bot", []string{"sync-bot"}))
+	assert.False(t, isBot("User", "foo", []string{"sync-bot"}))
+}
@@ -0,0 +1,258 @@
+package changelog
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+var (
+	// cherryPickRE matches the line added by git cherry-pick -x.
+	cherryPickRE = regexp.MustCompile(`(?m)^\(cherry picked from commit ([0-9a-f]{7,40})\)\s*$`)
+
+	// backportRefRE matches a line referencing the original PR in the title
+	// or body of a backport PR, in the forms used by backport bots like
+	// "Backport of #123" or "Backport 6a7b8c9 from #123".
+	backportRefRE = regexp.MustCompile(`(?im)^[ \t]*backport(?:s|ed)?(?: of)?(?: [0-9a-f]{7,40})?(?: from)? #([0-9]+)\b`)
+
+	// backportTitleRE matches the title of a backport PR prefixed with its
+	// target branch and ending with the original PR, like "[Backport 3.x]
+	// Fix foo (#123)".
+	backportTitleRE = regexp.MustCompile(`(?i)^\s*\[backport\b[^\]]*\].*\(#([0-9]+)\)\s*$`)
+)
+
+// backport records how the changes of an original PR reached the branch,
+// Number and URL are empty for commits cherry-picked without a PR.
+type backport struct {
+	Number int
+	URL    string
+}
+
+// cherryPickedCommits returns the commits named by cherry-pick lines in the
+// message.
+func cherryPickedCommits(message string) []string {
+	var shas []string
+	for _, match := range cherryPickRE.FindAllStringSubmatch(message, -1) {
+		shas = append(shas, match[1])
+	}
+	return shas
+}
+
+// backportReferences returns the PR numbers referenced as the original of a
+// backport in the title or body.
+func backportReferences(title, body string) []int {
+	var matches [][]string
+	matches = append(matches, backportTitleRE.FindAllStringSubmatch(title, -1)...)
+	matches = append(matches, backportRefRE.FindAllStringSubmatch(title, -1)...)
+	matches = append(matches, backportRefRE.FindAllStringSubmatch(body, -1)...)
+
+	var numbers []int
+	for _, match := range matches {
+		if n, err := strconv.Atoi(match[1]); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// resolveBackports replaces backport PRs on the branch with the original PRs
+// they reference, and adds the original PRs of commits cherry-picked onto the
+// branch without a PR. The originals take the merge time of their backport.
+// The returned map records the backport of each original by PR number.
+func resolveBackports(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	commits []commit,
+	prs []pullRequest,
+) ([]pullRequest, map[int]backport, error) {
+	resolver := &backportResolver{
+		client: client,
+		opts:   opts,
+		shas:   map[string][]string{},
+	}
+
+	included := map[string]bool{}
+	for _, pr := range prs {
+		included[pr.ID] = true
+	}
+
+	var originalIDs []string
+	mergedAt := map[string]time.Time{}
+	backportOf := map[string]backport{}
+	addOriginal := func(id string, merged time.Time, b backport) {
+		if _, ok := backportOf[id]; ok {
+			return
+		}
+		backportOf[id] = b
+		mergedAt[id] = merged
+		originalIDs = append(originalIDs, id)
+	}
+
+	var result []pullRequest
+	for _, pr := range prs {
+		logger := logger.With("pr", pr.Number)
+
+		var ids []string
+		for _, n := range pr.Commits.Nodes {
+			for _, sha := range cherryPickedCommits(n.Commit.Message) {
+				shaIDs, err := resolver.commitPullRequests(ctx, sha)
+				if err != nil {
+					return nil, nil, err
+				}
+				ids = append(ids, shaIDs...)
+			}
+		}
+		for _, number := range backportReferences(pr.Title, pr.Body) {
+			id, err := resolver.pullRequestID(ctx, number)
+			if err != nil {
+				// the reference may not be to a PR at all
+				logger.Warn("unable to look up backported PR", "original", number, "err", err)
+				continue
+			}
+			if id != "" {
+				ids = append(ids, id)
+			}
+		}
+
+		backported := false
+		for _, id := range ids {
+			if id == pr.ID || included[id] {
+				continue
+			}
+			backported = true
+			addOriginal(id, pr.MergedAt, backport{Number: pr.Number, URL: pr.URL})
+		}
+		if backported {
+			logger.Debug("replacing backport PR with its originals")
+			continue
+		}
+		result = append(result, pr)
+	}
+
+	for _, c := range commits {
+		for _, sha := range cherryPickedCommits(c.Message) {
+			ids, err := resolver.commitPullRequests(ctx, sha)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, id := range ids {
+				if included[id] {
+					continue
+				}
+				logger.Debug("found cherry-picked commit", "commit", c.OID, "original", sha)
+				addOriginal(id, c.CommittedDate, backport{})
+			}
+		}
+	}
+
+	if len(originalIDs) == 0 {
+		return result, nil, nil
+	}
+
+	logger.Info("retrieving backported PRs", "count", len(originalIDs))
+	fetched, err := fetchPullRequests(ctx, client, logger, opts.Cache, originalIDs, opts.needsFiles())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	backports := make(map[int]backport, len(fetched))
+	for _, pr := range fetched {
+		if l, ok := noNoteLabel(opts, pr); ok {
+			logger.Debug("skipping PR", "pr", pr.Number, "reason", "no note label", "label", l)
+			continue
+		}
+
+		pr.MergedAt = mergedAt[pr.ID]
+		backports[pr.Number] = backportOf[pr.ID]
+		result = append(result, pr)
+	}
+
+	return result, backports, nil
+}
+
+// applyBackports marks the notes of backported PRs.
+func applyBackports(notes []ReleaseNote, backports map[int]backport) {
+	for i, n := range notes {
+		b, ok := backports[n.PRNumber]
+		if !ok {
+			continue
+		}
+		notes[i].Backport = true
+		notes[i].BackportPRNumber = b.Number
+		notes[i].BackportPRURL = b.URL
+	}
+}
+
+// backportResolver looks up original PRs, caching the results as the same
+// commits are often named by both a backport PR and its merge commit.
+type backportResolver struct {
+	client *githubv4.Client
+	opts   Options
+	shas   map[string][]string
+}
+
+// commitPullRequests returns the IDs of the merged PRs of the repository
+// into other branches that contain the commit.
+func (r *backportResolver) commitPullRequests(ctx context.Context, sha string) ([]string, error) {
+	if ids, ok := r.shas[sha]; ok {
+		return ids, nil
+	}
+
+	prs, err := commitAssociatedPullRequests(ctx, r.client, r.opts.Owner, r.opts.Repo, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, pr := range prs {
+		if r.original(pr) {
+			ids = append(ids, pr.ID)
+		}
+	}
+
+	r.shas[sha] = ids
+	return ids, nil
+}
+
+// original returns true if the PR could be the original of a backport: it was
+// merged into another branch of the repository.
+func (r *backportResolver) original(pr associatedPullRequest) bool {
+	return pr.State == githubv4.PullRequestStateMerged &&
+		pr.BaseRef.Name != r.opts.Branch &&
+		strings.EqualFold(pr.BaseRef.Repository.Name, r.opts.Repo) &&
+		strings.EqualFold(pr.BaseRef.Repository.Owner.Login, r.opts.Owner)
+}
+
+// pullRequestID returns the ID of a PR by number, or an empty string if the
+// number is an issue or the PR is not the original of a backport.
+func (r *backportResolver) pullRequestID(ctx context.Context, number int) (string, error) {
+	var q struct {
+		Repository struct {
+			IssueOrPullRequest *struct {
+				PullRequest associatedPullRequest `graphql:"... on PullRequest"`
+			} `graphql:"issueOrPullRequest(number: $number)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := r.client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(r.opts.Owner),
+		"repoName":  githubv4.String(r.opts.Repo),
+		"number":    githubv4.Int(number),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ipr := q.Repository.IssueOrPullRequest
+	if ipr == nil || !r.original(ipr.PullRequest) {
+		return "", nil
+	}
+	return ipr.PullRequest.ID, nil
+}
@@ -0,0 +1,136 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCherryPickedCommits(t *testing.T) {
+	for i, c := range []struct {
+		expected []string
+		message  string
+	}{
+		{nil, ""},
+		{nil, "Fix foo"},
+		{[]string{"0123456789abcdef0123456789abcdef01234567"}, "Fix foo\n\n(cherry picked from commit 0123456789abcdef0123456789abcdef01234567)"},
+		{[]string{"abc1234", "def5678"}, "Fix foo\n\n(cherry picked from commit abc1234)\n(cherry picked from commit def5678)\n"},
+		{nil, "Fix foo (cherry picked from commit abc1234)"},
+		{nil, "(cherry picked from commit not-a-sha)"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.message), func(t *testing.T) {
+			assert.Equal(t, c.expected, cherryPickedCommits(c.message))
+		})
+	}
+}
+
+func TestBackportReferences(t *testing.T) {
+	for i, c := range []struct {
+		expected []int
+		title    string
+		body     string
+	}{
+		{nil, "Fix foo", ""},
+		{nil, "Fix foo (#123)", "Fixes #45"},
+		{[]int{123}, "Backport of #123", ""},
+		{[]int{123}, "[Backport 1.x] Fix foo (#123)", ""},
+		{[]int{123}, "[1.x] Fix foo", "Backport 6a7b8c9 from #123."},
+		{[]int{123}, "[release/1.x] Fix foo", "Backported from #123"},
+		{[]int{123, 124}, "Backport #123", "Backports #124"},
+		{nil, "Backport fixes", "\n#123"},
+		// only the forms used by backport bots reference the original
+		{nil, "Fix foo", "This was backported from #123"},
+		{nil, "Fix backport bot, follow-up to #120", ""},
+		{nil, "[Backport 1.x] Fix foo (#123) again", ""},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.title), func(t *testing.T) {
+			assert.Equal(t, c.expected, backportReferences(c.title, c.body))
+		})
+	}
+}
+
+func TestApplyBackports(t *testing.T) {
+	notes := []ReleaseNote{
+		{PRNumber: 1},
+		{PRNumber: 2},
+		{PRNumber: 3},
+	}
+	applyBackports(notes, map[int]backport{
+		1: {Number: 10, URL: "https://github.com/foo/bar/pull/10"},
+		3: {},
+	})
+
+	assert.Equal(t, []ReleaseNote{
+		{PRNumber: 1, Backport: true, BackportPRNumber: 10, BackportPRURL: "https://github.com/foo/bar/pull/10"},
+		{PRNumber: 2},
+		{PRNumber: 3, Backport: true},
+	}, notes)
+}
+
+func TestCollectReleaseNotes_backports(t *testing.T) {
+	api := &fakeGraphQL{
+		prs:         map[string]map[string]interface{}{},
+		otherBranch: map[string]string{"abc1234": "pr6"},
+		bases:       map[string]string{"pr5": "develop", "pr6": "develop"},
+	}
+	// originals merged into another branch, so not in the history, and a PR
+	// merged into the branch before the range
+	api.addPR(4, "earlier fix", day(1))
+	api.addPR(5, "original", day(1))
+	api.addPR(6, "cherry-picked", day(1))
+	api.commits = nil
+
+	// neither a PR mentioning a backport nor a backport of a PR merged into
+	// the branch is replaced
+	api.addPR(7, "Fix backport bot, follow-up to #4", day(2))
+	api.addPR(8, "Backport of #4", day(2))
+
+	api.addPR(10, "Backport of #5", day(3))
+	api.addPR(11, "Fix foo on 1.x", day(4))
+	api.prs["pr11"]["commits"] = map[string]interface{}{
+		"nodes": []interface{}{
+			map[string]interface{}{
+				"commit": map[string]interface{}{
+					"message": "Fix foo\n\n(cherry picked from commit abc1234)",
+					"author":  map[string]interface{}{},
+				},
+			},
+		},
+	}
+	api.addPR(12, "direct", day(5))
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	// the owner and repo are matched regardless of case
+	opts := Options{Owner: "Foo", Repo: "Bar", Branch: "main", Backports: true}
+
+	notes, err := CollectReleaseNotes(context.Background(), client, hclog.NewNullLogger(), opts, day(1), day(10))
+	assert.NoError(t, err)
+
+	type summary struct {
+		Text             string
+		PRNumber         int
+		PRDate           time.Time
+		BackportPRNumber int
+	}
+	var summaries []summary
+	for _, n := range notes {
+		assert.True(t, n.Backport == (n.PRNumber == 5 || n.PRNumber == 6), "PR %d", n.PRNumber)
+		summaries = append(summaries, summary{n.Text, n.PRNumber, n.PRDate, n.BackportPRNumber})
+	}
+	assert.Equal(t, []summary{
+		{"direct", 12, day(5), 0},
+		{"cherry-picked", 6, day(4), 11},
+		{"original", 5, day(3), 10},
+		{"Fix backport bot, follow-up to #4", 7, day(2), 0},
+		{"Backport of #4", 8, day(2), 0},
+	}, summaries)
+}
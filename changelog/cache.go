@@ -0,0 +1,95 @@
+package changelog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	cacheKindCommit      = "commits"
+	cacheKindPullRequest = "pull-requests"
+
+	// cacheVersion is part of every cache path, bump it when the cached
+	// types change so older entries missing fields are not used
+	cacheVersion = "v5"
+)
+
+// Cache stores GitHub responses on disk so repeated runs over the same range
+// do not need to fetch them again. Commit to PR associations are keyed by
+// repository and commit OID, and PR data is keyed by the PR node ID and the
+// time it was last updated, so edited PRs are fetched again.
+type Cache struct {
+	dir string
+}
+
+// NewCache returns a cache that stores responses under dir.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir: dir,
+	}
+}
+
+// Clear removes all cached responses.
+func (c *Cache) Clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+func (c *Cache) path(kind string, keyParts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(keyParts, "\x00")))
+	return filepath.Join(c.dir, cacheVersion, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+// get loads a cached value into v, returning false if it is not cached or
+// cannot be read.
+func (c *Cache) get(v interface{}, kind string, keyParts ...string) bool {
+	if c == nil {
+		return false
+	}
+
+	content, err := ioutil.ReadFile(c.path(kind, keyParts...))
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(content, v) == nil
+}
+
+// put stores v in the cache.
+func (c *Cache) put(v interface{}, kind string, keyParts ...string) error {
+	if c == nil {
+		return nil
+	}
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	p := c.path(kind, keyParts...)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename so concurrent runs never read a
+	// partially written entry
+	tmp, err := ioutil.TempFile(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
@@ -0,0 +1,40 @@
+package changelog
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changelog-gen-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := NewCache(dir)
+
+	var pr pullRequest
+	assert.False(t, cache.get(&pr, cacheKindPullRequest, "id", "2020-01-01T00:00:00Z"))
+
+	expected := pullRequest{ID: "id", Number: 1, Title: "foo", Body: "bar"}
+	assert.NoError(t, cache.put(expected, cacheKindPullRequest, "id", "2020-01-01T00:00:00Z"))
+
+	assert.True(t, cache.get(&pr, cacheKindPullRequest, "id", "2020-01-01T00:00:00Z"))
+	assert.Equal(t, expected, pr)
+
+	// a different updated time is a miss
+	assert.False(t, cache.get(&pr, cacheKindPullRequest, "id", "2020-01-02T00:00:00Z"))
+
+	assert.NoError(t, cache.Clear())
+	assert.False(t, cache.get(&pr, cacheKindPullRequest, "id", "2020-01-01T00:00:00Z"))
+}
+
+func TestCache_nil(t *testing.T) {
+	var cache *Cache
+
+	var pr pullRequest
+	assert.NoError(t, cache.put(pullRequest{ID: "id"}, cacheKindPullRequest, "id"))
+	assert.False(t, cache.get(&pr, cacheKindPullRequest, "id"))
+}
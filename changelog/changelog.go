@@ -3,7 +3,7 @@ package changelog
 import (
 	"context"
 	"errors"
-	"sort"
+	"fmt"
 	"time"
 
 	hclog "github.com/hashicorp/go-hclog"
@@ -39,31 +39,362 @@ func TimeFromCommit(
 	return q.Repository.Object.Commit.CommittedDate, nil
 }
 
+// TimeFromTag returns the commit time of the commit a tag points to, for both
+// lightweight and annotated tags.
+func TimeFromTag(
+	ctx context.Context,
+	client *githubv4.Client,
+	owner, repo, tag string,
+) (time.Time, error) {
+	type commitFragment struct {
+		CommittedDate time.Time
+	}
+	var q struct {
+		Repository struct {
+			Ref *struct {
+				Target struct {
+					Commit commitFragment `graphql:"... on Commit"`
+					Tag    struct {
+						Target struct {
+							Commit commitFragment `graphql:"... on Commit"`
+						}
+					} `graphql:"... on Tag"`
+				}
+			} `graphql:"ref(qualifiedName: $ref)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(owner),
+		"repoName":  githubv4.String(repo),
+		"ref":       githubv4.String("refs/tags/" + tag),
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if q.Repository.Ref == nil {
+		return time.Time{}, errors.New("unable to find tag")
+	}
+	if t := q.Repository.Ref.Target.Commit.CommittedDate; !t.IsZero() {
+		return t, nil
+	}
+	if t := q.Repository.Ref.Target.Tag.Target.Commit.CommittedDate; !t.IsZero() {
+		return t, nil
+	}
+	return time.Time{}, errors.New("tag does not point to a commit")
+}
+
+// Options configures how BuildChangelog collects and renders release notes.
+type Options struct {
+	Owner  string
+	Repo   string
+	Branch string
+
+	// StartRef and EndRef are the commits or tags at the start and end of
+	// the range, they are only used to describe the range to templates.
+	StartRef string
+	EndRef   string
+
+	// Version is the version of the release, available to the changelog
+	// template as Version. If empty the suggested next version is used.
+	Version string
+
+	// NoNoteLabels are labels that exclude a PR from the changelog.
+	NoNoteLabels []string
+
+	// Paths filters PRs by the files they change, for example to generate
+	// the changelog of a single component in a monorepo.
+	Paths PathFilter
+
+	// TypeLabels assign a type to release notes that did not specify one in
+	// their block.
+	TypeLabels []TypeLabel
+
+	// AllowedTypes are the release note types expected in the changelog. If
+	// set, notes with any other type are logged. The "none" type is always
+	// allowed.
+	AllowedTypes []string
+
+	// Strict causes BuildChangelog to fail if any notes have a type that is
+	// not allowed.
+	Strict bool
+
+	// Backports enables including PRs merged into other branches whose
+	// changes were backported to Branch, by a backport PR or by cherry-picking
+	// commits with "git cherry-pick -x". Backport PRs are replaced by the PRs
+	// they backport.
+	Backports bool
+
+	// SortKeys order the notes, ties are broken by PR number, block order and
+	// text so the order is always the same. If empty DefaultSortKeys are
+	// used.
+	SortKeys []SortKey
+
+	// PreviousChangelog is the markdown of an existing changelog, notes
+	// already listed in one of its released sections are excluded, either
+	// by a link to their PR or by their text. A section starts with a level
+	// 2 heading, sections headed "Unreleased" or with Version are not
+	// considered released.
+	PreviousChangelog string
+
+	// AuthorPrefixes are additional line prefixes, like "Contributed-by:",
+	// that override the author of a PR when followed by @login in its body.
+	AuthorPrefixes []string
+
+	// BotLogins are accounts treated as bots in addition to GitHub Apps and
+	// "[bot]" suffixed logins. PRs opened by bots without an author override
+	// are credited to their first non-bot commit author.
+	BotLogins []string
+
+	// ExcludeBots skips all PRs opened by bots. Otherwise bot PRs without an
+	// author override are credited to their first non-bot commit author.
+	ExcludeBots bool
+
+	// FirstTimeContributors enables checking whether each contributor had
+	// any PRs merged before the changelog, which requires a search per
+	// contributor.
+	FirstTimeContributors bool
+
+	// CurrentVersion is the semantic version of the release at the start of
+	// the range, if set the suggested next version is available to the
+	// changelog template as NextVersion.
+	CurrentVersion string
+
+	// BumpRules map release note types to the version bump they require,
+	// taking precedence over DefaultBumpRules.
+	BumpRules []BumpRule
+
+	// Prerelease is the pre-release identifier of the next version, like
+	// "beta", if empty the next version is a release.
+	Prerelease string
+
+	// Cache, if set, is used to reuse GitHub responses across runs.
+	Cache *Cache
+
+	// State, if set, makes collecting release notes incremental: only the
+	// history after its last commit is scanned, and it is updated with the
+	// results so the caller can save it for the next run.
+	State *State
+
+	// ChangelogTemplate and ReleaseNoteTemplate are the template text used to
+	// render the changelog, if empty the built-in templates are used.
+	ChangelogTemplate   string
+	ReleaseNoteTemplate string
+
+	// componentPaths is set when building component changelogs with path
+	// filters, which need the changed files even though Paths is empty.
+	componentPaths bool
+}
+
+// needsFiles returns true if the changed files of PRs are needed to filter
+// them by path. They are only fetched then, as paging through the files of
+// large PRs is expensive.
+func (o Options) needsFiles() bool {
+	return !o.Paths.IsZero() || o.componentPaths
+}
+
+// BuildChangelog collects the release notes for the PRs merged between start
+// and end and renders them as a changelog.
 func BuildChangelog(
 	ctx context.Context,
 	client *githubv4.Client,
 	logger hclog.Logger,
-	changelogTemplate,
-	releaseNoteTemplate,
-	owner, repo, branch string, noNoteLabels []string,
+	opts Options,
 	start, end time.Time,
 ) (string, error) {
-	prIDs, err := listPullRequestIDs(ctx, client, logger, owner, repo, branch, noNoteLabels, start, end)
+	data, err := BuildChangelogData(ctx, client, logger, opts, start, end)
 	if err != nil {
 		return "", err
 	}
 
+	return RenderChangelog(opts.ChangelogTemplate, opts.ReleaseNoteTemplate, data)
+}
+
+// BuildChangelogData collects the release notes for the PRs merged between
+// start and end along with the rest of the changelog template data, so it
+// can be rendered with more than one template.
+func BuildChangelogData(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	start, end time.Time,
+) (ChangelogData, error) {
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return ChangelogData{}, err
+	}
+
+	contributors, err := collectContributors(ctx, client, logger, opts, start, notes)
+	if err != nil {
+		return ChangelogData{}, err
+	}
+
+	next, err := nextVersion(opts, opts.CurrentVersion, notes)
+	if err != nil {
+		return ChangelogData{}, err
+	}
+
+	data := changelogData(opts, start, end, notes)
+	data.Contributors = contributors
+	data.NextVersion = next
+	if data.Version == "" {
+		data.Version = next
+	}
+
+	return data, nil
+}
+
+// changelogData returns the template data for the notes of the range,
+// generated now.
+func changelogData(opts Options, start, end time.Time, notes []ReleaseNote) ChangelogData {
+	return ChangelogData{
+		Notes:     notes,
+		Owner:     opts.Owner,
+		Repo:      opts.Repo,
+		Branch:    opts.Branch,
+		StartRef:  opts.StartRef,
+		EndRef:    opts.EndRef,
+		Start:     start,
+		End:       end,
+		Version:   opts.Version,
+		Generated: time.Now().UTC(),
+	}
+}
+
+// nextVersion returns the version following current for the notes, or an
+// empty string if current is not set.
+func nextVersion(opts Options, current string, notes []ReleaseNote) (string, error) {
+	if current == "" {
+		return "", nil
+	}
+	next, err := NextVersion(current, SuggestBump(notes, opts.BumpRules), opts.Prerelease)
+	if err != nil {
+		return "", fmt.Errorf("error suggesting next version: %w", err)
+	}
+	return next, nil
+}
+
+// collectContributors returns the contributors of the notes, detecting first
+// time contributors if enabled.
+func collectContributors(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	start time.Time,
+	notes []ReleaseNote,
+) ([]Contributor, error) {
+	contributors := contributorsFromNotes(notes)
+	if opts.FirstTimeContributors {
+		logger.Info("checking for first time contributors", "count", len(contributors))
+		err := detectFirstTimeContributors(ctx, client, logger, opts.Owner, opts.Repo, start, authorPatterns(opts.AuthorPrefixes), contributors)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return contributors, nil
+}
+
+// CollectReleaseNotes returns the release notes for the PRs merged between
+// start and end, ordered by the sort keys of opts.
+func CollectReleaseNotes(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	start, end time.Time,
+) ([]ReleaseNote, error) {
+	if opts.State != nil {
+		return collectReleaseNotesIncremental(ctx, client, logger, opts, opts.State, start, end)
+	}
+
+	prs, backports, err := collectPullRequests(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	reverts, err := resolveReverts(ctx, client, logger, opts, prs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving reverts: %w", err)
+	}
+
+	notes := releaseNotesFromPullRequests(logger, opts, prs)
+	applyBackports(notes, backports)
+	applyReverts(notes, opts.Owner, opts.Repo, reverts)
+	return finishReleaseNotes(logger, opts, notes)
+}
+
+// collectPullRequests returns the merged PRs between start and end. If
+// backports are enabled, backported PRs are also returned along with how
+// they were backported, by PR number.
+func collectPullRequests(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	start, end time.Time,
+) ([]pullRequest, map[int]backport, error) {
+	logger.Info("checking commits for associated PRs", "since", start, "until", end)
+	commits, err := listCommits(ctx, client, opts.Owner, opts.Repo, opts.Branch, start, end)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing pull requests: %w", err)
+	}
+
+	prIDs, err := commitPullRequestIDs(ctx, client, logger, opts, commits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing pull requests: %w", err)
+	}
+
 	logger.Info("found PRs", "count", len(prIDs))
 
-	notes, err := pullRequestsToReleaseNotes(ctx, client, logger, prIDs)
+	logger.Info("retrieving PRs to build release notes")
+	prs, err := fetchPullRequests(ctx, client, logger, opts.Cache, prIDs, opts.needsFiles())
 	if err != nil {
-		return "", err
+		return nil, nil, fmt.Errorf("error retrieving pull requests: %w", err)
 	}
+	prs = dropNoNoteLabels(logger, opts, prs)
 
-	sort.Slice(notes, func(i int, j int) bool {
-		return notes[i].PRDate.After(notes[j].PRDate)
-	})
+	if !opts.Backports {
+		return prs, nil, nil
+	}
 
+	prs, backports, err := resolveBackports(ctx, client, logger, opts, commits, prs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving backports: %w", err)
+	}
+	return prs, backports, nil
+}
+
+// finishReleaseNotes excludes previously released, reverted and duplicate
+// notes, sorts them by the sort keys, and checks their types.
+func finishReleaseNotes(logger hclog.Logger, opts Options, notes []ReleaseNote) ([]ReleaseNote, error) {
+	if opts.PreviousChangelog != "" {
+		notes = excludeReleasedNotes(logger, notes, opts.PreviousChangelog, opts.Version)
+	}
+
+	// duplicates are dropped in favor of the most recently merged note
+	sortReleaseNotes(notes, DefaultSortKeys)
+	notes = dropRevertedPairs(logger, notes)
+	notes = dedupeReleaseNotes(logger, notes)
+	sortReleaseNotes(notes, opts.SortKeys)
+
+	if len(opts.AllowedTypes) > 0 {
+		unknown := checkTypes(logger, notes, opts.AllowedTypes)
+		if unknown > 0 && opts.Strict {
+			return nil, fmt.Errorf("%d release notes have a type that is not allowed", unknown)
+		}
+	}
+
+	return notes, nil
+}
+
+// RenderChangelog renders data using the changelog and release note
+// templates, if either template is empty the built-in one is used. Changelog
+// templates that range over their root are given the notes instead of data
+// for compatibility. The contributors are also available to the changelog
+// template via the contributors function.
+func RenderChangelog(changelogTemplate, releaseNoteTemplate string, data ChangelogData) (string, error) {
 	if changelogTemplate == "" {
 		changelogTemplate = defaultChangelogTemplate
 	}
@@ -72,5 +403,107 @@ func BuildChangelog(
 		releaseNoteTemplate = defaultReleaseNoteTemplate
 	}
 
-	return renderChangelog(changelogTemplate, releaseNoteTemplate, notes)
+	cl, err := renderChangelog(changelogTemplate, releaseNoteTemplate, data)
+	if err != nil {
+		return "", fmt.Errorf("error rendering changelog: %w", err)
+	}
+	return cl, nil
+}
+
+// RenderReleaseNote renders a single note using the release note template, if
+// the template is empty the built-in one is used.
+func RenderReleaseNote(releaseNoteTemplate string, note ReleaseNote) (string, error) {
+	if releaseNoteTemplate == "" {
+		releaseNoteTemplate = defaultReleaseNoteTemplate
+	}
+
+	rn, err := renderReleaseNoteFunc(releaseNoteTemplate)(note)
+	if err != nil {
+		return "", fmt.Errorf("error rendering release note: %w", err)
+	}
+	return rn, nil
+}
+
+// checkTypes logs a warning for each note whose type is not in allowedTypes
+// and returns the number of such notes.
+func checkTypes(logger hclog.Logger, notes []ReleaseNote, allowedTypes []string) int {
+	unknown := 0
+	for _, note := range notes {
+		if note.Type == typeNone || stringInSlice(allowedTypes, note.Type) {
+			continue
+		}
+		unknown++
+		logger.Warn("release note type is not allowed", "type", note.Type, "pr", note.PRNumber, "url", note.PRURL)
+	}
+	return unknown
+}
+
+// PullRequestBody returns the title and body of a pull request by number.
+func PullRequestBody(
+	ctx context.Context,
+	client *githubv4.Client,
+	owner, repo string, number int,
+) (string, string, error) {
+	var q struct {
+		Repository struct {
+			PullRequest *struct {
+				Title string
+				Body  string
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(owner),
+		"repoName":  githubv4.String(repo),
+		"number":    githubv4.Int(number),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if q.Repository.PullRequest == nil {
+		return "", "", errors.New("unable to find pull request")
+	}
+	return q.Repository.PullRequest.Title, q.Repository.PullRequest.Body, nil
+}
+
+// PullRequestReleaseNotes returns the release notes for a single pull request
+// in the repository of opts by number, regardless of its state or labels.
+func PullRequestReleaseNotes(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	number int,
+) ([]ReleaseNote, error) {
+	id, err := pullRequestNodeID(ctx, client, opts.Owner, opts.Repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return pullRequestsToReleaseNotes(ctx, client, logger, opts, []string{id})
+}
+
+// pullRequestNodeID returns the node ID of the pull request by number.
+func pullRequestNodeID(ctx context.Context, client *githubv4.Client, owner, repo string, number int) (string, error) {
+	var q struct {
+		Repository struct {
+			PullRequest *struct {
+				ID string
+			} `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(owner),
+		"repoName":  githubv4.String(repo),
+		"number":    githubv4.Int(number),
+	})
+	if err != nil {
+		return "", err
+	}
+	if q.Repository.PullRequest == nil {
+		return "", errors.New("unable to find pull request")
+	}
+	return q.Repository.PullRequest.ID, nil
 }
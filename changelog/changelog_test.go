@@ -1 +1,21 @@
 package changelog
+
+import (
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTypes(t *testing.T) {
+	notes := []ReleaseNote{
+		{Type: "bug"},
+		{Type: "enhancement"},
+		{Type: "none"},
+		{Type: "feature"},
+		{Type: ""},
+	}
+
+	assert.Equal(t, 2, checkTypes(hclog.NewNullLogger(), notes, []string{"bug", "enhancement"}))
+	assert.Equal(t, 1, checkTypes(hclog.NewNullLogger(), notes, []string{"bug", "enhancement", "feature"}))
+}
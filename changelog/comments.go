@@ -0,0 +1,136 @@
+package changelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// maxCommentPages limits how many pages of comments are searched for an
+// existing sticky comment.
+const maxCommentPages = 10
+
+// IssueComment is a comment on an issue or PR.
+type IssueComment struct {
+	ID      int64  `json:"id,omitempty"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url,omitempty"`
+
+	// User and PerformedViaGitHubApp are set on comments read from GitHub
+	User                  *commentUser `json:"user,omitempty"`
+	PerformedViaGitHubApp *struct {
+		Slug string `json:"slug"`
+	} `json:"performed_via_github_app,omitempty"`
+}
+
+type commentUser struct {
+	Login string `json:"login"`
+}
+
+// commentAuthor identifies the comments made with the client's token, by the
+// login of its user or, for GitHub App tokens that cannot read their user, as
+// comments made by an app.
+type commentAuthor struct {
+	login string
+}
+
+// currentCommentAuthor looks up the user of the client's token.
+func currentCommentAuthor(ctx context.Context, client *RESTClient, logger hclog.Logger) (commentAuthor, error) {
+	var u commentUser
+	err := client.do(ctx, http.MethodGet, "user", nil, &u)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		// installation tokens are not a user
+		logger.Debug("unable to look up token user, matching comments made by an app", "err", err)
+		return commentAuthor{}, nil
+	}
+	if err != nil {
+		return commentAuthor{}, fmt.Errorf("error looking up token user: %w", err)
+	}
+	return commentAuthor{login: u.Login}, nil
+}
+
+// wrote returns true if the comment was made by the author.
+func (a commentAuthor) wrote(c IssueComment) bool {
+	if a.login == "" {
+		return c.PerformedViaGitHubApp != nil
+	}
+	return c.User != nil && strings.EqualFold(c.User.Login, a.login)
+}
+
+// findComment returns the first comment on the issue or PR by author
+// containing marker. Comments by others are ignored, anyone can copy the
+// marker but the comment could not be updated.
+func findComment(ctx context.Context, client *RESTClient, owner, repo string, number int, author commentAuthor, marker string) (*IssueComment, error) {
+	for page := 1; page <= maxCommentPages; page++ {
+		var comments []IssueComment
+		path := fmt.Sprintf("repos/%s/%s/issues/%d/comments?per_page=100&page=%d", owner, repo, number, page)
+		if err := client.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			if author.wrote(c) && strings.Contains(c.Body, marker) {
+				return &c, nil
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+	return nil, nil
+}
+
+// UpsertComment maintains a single sticky comment on the issue or PR,
+// identified by marker, which is prepended to the body if it does not
+// already contain it. The comment is created if it does not exist, and only
+// updated if the body changed.
+func UpsertComment(
+	ctx context.Context,
+	client *RESTClient,
+	logger hclog.Logger,
+	owner, repo string, number int,
+	marker, body string,
+) (IssueComment, error) {
+	logger = logger.With("pr", number)
+
+	if !strings.Contains(body, marker) {
+		body = marker + "\n" + body
+	}
+
+	author, err := currentCommentAuthor(ctx, client, logger)
+	if err != nil {
+		return IssueComment{}, err
+	}
+
+	existing, err := findComment(ctx, client, owner, repo, number, author, marker)
+	if err != nil {
+		return IssueComment{}, fmt.Errorf("error finding comment: %w", err)
+	}
+
+	if existing == nil {
+		var created IssueComment
+		path := fmt.Sprintf("repos/%s/%s/issues/%d/comments", owner, repo, number)
+		if err := client.do(ctx, http.MethodPost, path, IssueComment{Body: body}, &created); err != nil {
+			return IssueComment{}, fmt.Errorf("error creating comment: %w", err)
+		}
+		logger.Info("created comment", "url", created.HTMLURL)
+		return created, nil
+	}
+
+	if existing.Body == body {
+		logger.Info("comment is up to date", "url", existing.HTMLURL)
+		return *existing, nil
+	}
+
+	var updated IssueComment
+	path := fmt.Sprintf("repos/%s/%s/issues/comments/%d", owner, repo, existing.ID)
+	if err := client.do(ctx, http.MethodPatch, path, IssueComment{Body: body}, &updated); err != nil {
+		return IssueComment{}, fmt.Errorf("error updating comment: %w", err)
+	}
+	logger.Info("updated comment", "url", updated.HTMLURL)
+	return updated, nil
+}
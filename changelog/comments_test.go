@@ -0,0 +1,125 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCommentsAPI is an in-memory implementation of the issue comment
+// endpoints of the GitHub REST API for PR 1 of foo/bar. Comments are made
+// by login, or by an app if login is empty.
+type fakeCommentsAPI struct {
+	mu       sync.Mutex
+	login    string
+	comments []IssueComment
+	writes   int
+}
+
+func (f *fakeCommentsAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var in IssueComment
+	if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.writes++
+	}
+
+	const commentPrefix = "/repos/foo/bar/issues/comments/"
+	if f.login == "" {
+		in.User = &commentUser{Login: "changelog[bot]"}
+		in.PerformedViaGitHubApp = &struct {
+			Slug string `json:"slug"`
+		}{"changelog"}
+	} else {
+		in.User = &commentUser{Login: f.login}
+	}
+
+	switch {
+	case r.URL.Path == "/user" && r.Method == http.MethodGet:
+		if f.login == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(commentUser{Login: f.login})
+	case r.URL.Path == "/repos/foo/bar/issues/1/comments" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.comments)
+	case r.URL.Path == "/repos/foo/bar/issues/1/comments" && r.Method == http.MethodPost:
+		in.ID = int64(len(f.comments) + 1)
+		f.comments = append(f.comments, in)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(in)
+	case strings.HasPrefix(r.URL.Path, commentPrefix) && r.Method == http.MethodPatch:
+		id, _ := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, commentPrefix), 10, 64)
+		for i, c := range f.comments {
+			if c.ID != id {
+				continue
+			}
+			in.ID = id
+			f.comments[i] = in
+			json.NewEncoder(w).Encode(in)
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestUpsertComment(t *testing.T) {
+	const marker = "<!-- test -->"
+
+	for _, login := range []string{"changelog-bot", ""} {
+		t.Run(login, func(t *testing.T) {
+			api := &fakeCommentsAPI{
+				login: login,
+				comments: []IssueComment{
+					{ID: 1, Body: "LGTM", User: &commentUser{Login: "foo"}},
+					// the marker copied by someone else
+					{ID: 2, Body: marker + "\nfake", User: &commentUser{Login: "foo"}},
+				},
+			}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			client, err := NewRESTClient(server.Client(), server.URL)
+			assert.NoError(t, err)
+
+			ctx := context.Background()
+			logger := hclog.NewNullLogger()
+
+			created, err := UpsertComment(ctx, client, logger, "foo", "bar", 1, marker, "first")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), created.ID)
+			assert.Equal(t, "<!-- test -->\nfirst", created.Body)
+
+			_, err = UpsertComment(ctx, client, logger, "foo", "bar", 1, marker, "first")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, api.writes)
+
+			updated, err := UpsertComment(ctx, client, logger, "foo", "bar", 1, marker, "second")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), updated.ID)
+			assert.Equal(t, 2, api.writes)
+
+			var bodies []string
+			for _, c := range api.comments {
+				bodies = append(bodies, c.Body)
+			}
+			assert.Equal(t, []string{"LGTM", marker + "\nfake", marker + "\nsecond"}, bodies)
+		})
+	}
+}
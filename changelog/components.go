@@ -0,0 +1,203 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+// Component is a part of a monorepo that has its own changelog, made up of
+// the PRs that change its paths and were merged between Start and End.
+type Component struct {
+	Name  string
+	Paths PathFilter
+
+	Start time.Time
+	End   time.Time
+
+	// StartRef and EndRef are the commits or tags at the start and end of
+	// the component's range.
+	StartRef string
+	EndRef   string
+
+	// CurrentVersion and Version override the CurrentVersion and Version of
+	// Options for this component.
+	CurrentVersion string
+	Version        string
+
+	// ChangelogTemplate and ReleaseNoteTemplate override the templates in
+	// Options for this component.
+	ChangelogTemplate   string
+	ReleaseNoteTemplate string
+}
+
+// ComponentChangelog is the rendered changelog of a Component.
+type ComponentChangelog struct {
+	Name      string
+	Changelog string
+}
+
+// BuildComponentChangelogs builds the changelog of each component. GitHub is
+// queried once for the union of the component ranges, and the PRs are then
+// partitioned by merge time and changed paths. A PR is in a component if one
+// of its changed files matches both the Paths of opts and of the component.
+// First time contributors are looked up once for all components, relative to
+// the earliest start.
+func BuildComponentChangelogs(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	components []Component,
+) ([]ComponentChangelog, error) {
+	if len(components) == 0 {
+		return nil, nil
+	}
+
+	start, end := components[0].Start, components[0].End
+	for _, c := range components[1:] {
+		if c.Start.Before(start) {
+			start = c.Start
+		}
+		if c.End.After(end) {
+			end = c.End
+		}
+	}
+
+	for _, c := range components {
+		if !c.Paths.IsZero() {
+			opts.componentPaths = true
+		}
+	}
+	prs, backports, err := collectPullRequests(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	reverts, err := resolveReverts(ctx, client, logger, opts, prs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving reverts: %w", err)
+	}
+
+	componentNotes, err := componentReleaseNotes(logger, opts, components, prs, backports, reverts)
+	if err != nil {
+		return nil, err
+	}
+
+	var allNotes []ReleaseNote
+	for _, notes := range componentNotes {
+		allNotes = append(allNotes, notes...)
+	}
+	allContributors, err := collectContributors(ctx, client, logger, opts, start, allNotes)
+	if err != nil {
+		return nil, err
+	}
+	firstTime := map[string]bool{}
+	for _, c := range allContributors {
+		firstTime[strings.ToLower(c.Login)] = c.FirstTime
+	}
+
+	changelogs := make([]ComponentChangelog, 0, len(components))
+	for i, c := range components {
+		notes := componentNotes[i]
+
+		contributors := contributorsFromNotes(notes)
+		for j, contributor := range contributors {
+			contributors[j].FirstTime = firstTime[strings.ToLower(contributor.Login)]
+		}
+
+		changelogTemplate := opts.ChangelogTemplate
+		if c.ChangelogTemplate != "" {
+			changelogTemplate = c.ChangelogTemplate
+		}
+		releaseNoteTemplate := opts.ReleaseNoteTemplate
+		if c.ReleaseNoteTemplate != "" {
+			releaseNoteTemplate = c.ReleaseNoteTemplate
+		}
+
+		currentVersion := opts.CurrentVersion
+		if c.CurrentVersion != "" {
+			currentVersion = c.CurrentVersion
+		}
+		next, err := nextVersion(opts, currentVersion, notes)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", c.Name, err)
+		}
+
+		componentOpts := opts
+		componentOpts.StartRef = c.StartRef
+		componentOpts.EndRef = c.EndRef
+		if c.Version != "" {
+			componentOpts.Version = c.Version
+		}
+		data := changelogData(componentOpts, c.Start, c.End, notes)
+		data.Component = c.Name
+		data.Contributors = contributors
+		data.NextVersion = next
+		if data.Version == "" {
+			data.Version = next
+		}
+
+		cl, err := RenderChangelog(changelogTemplate, releaseNoteTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", c.Name, err)
+		}
+
+		changelogs = append(changelogs, ComponentChangelog{
+			Name:      c.Name,
+			Changelog: cl,
+		})
+	}
+
+	return changelogs, nil
+}
+
+// componentReleaseNotes partitions the PRs by component and returns the
+// release notes of each component, in the same order as components.
+func componentReleaseNotes(
+	logger hclog.Logger,
+	opts Options,
+	components []Component,
+	prs []pullRequest,
+	backports map[int]backport,
+	reverts map[int]int,
+) ([][]ReleaseNote, error) {
+	componentNotes := make([][]ReleaseNote, 0, len(components))
+	for _, c := range components {
+		logger := logger.With("component", c.Name)
+
+		filters := []PathFilter{opts.Paths, c.Paths}
+		var componentPRs []pullRequest
+		for _, pr := range prs {
+			if pr.MergedAt.Before(c.Start) || pr.MergedAt.After(c.End) {
+				continue
+			}
+			if !matchAllFilters(filters, pr.files()) {
+				logger.Debug("skipping PR", "pr", pr.Number, "reason", "no matching paths", "files", len(pr.Files.Nodes))
+				continue
+			}
+			componentPRs = append(componentPRs, pr)
+		}
+
+		// the PRs are already filtered by both paths
+		componentOpts := opts
+		componentOpts.Paths = PathFilter{}
+
+		notes := releaseNotesFromPullRequests(logger, componentOpts, componentPRs)
+		applyBackports(notes, backports)
+		applyReverts(notes, opts.Owner, opts.Repo, reverts)
+		notes, err := finishReleaseNotes(logger, componentOpts, notes)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", c.Name, err)
+		}
+
+		logger.Info("built component release notes", "count", len(notes))
+		componentNotes = append(componentNotes, notes)
+	}
+
+	return componentNotes, nil
+}
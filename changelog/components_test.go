@@ -0,0 +1,53 @@
+package changelog
+
+import (
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPullRequest(number int, title string, mergedAt time.Time, files ...string) pullRequest {
+	pr := pullRequest{
+		Number:   number,
+		Title:    title,
+		MergedAt: mergedAt,
+	}
+	for _, f := range files {
+		pr.Files.Nodes = append(pr.Files.Nodes, struct{ Path string }{f})
+	}
+	return pr
+}
+
+func TestComponentReleaseNotes(t *testing.T) {
+	prs := []pullRequest{
+		testPullRequest(1, "api change", day(2), "services/api/main.go"),
+		testPullRequest(2, "web change", day(3), "services/web/main.go"),
+		testPullRequest(3, "shared change", day(4), "services/api/main.go", "services/web/main.go"),
+		testPullRequest(4, "late api change", day(10), "services/api/main.go"),
+		// excluded by the global path filter
+		testPullRequest(5, "api test", day(3), "services/api/main_test.go"),
+	}
+
+	opts := Options{Paths: PathFilter{Exclude: []string{"**/*_test.go"}}}
+	actual, err := componentReleaseNotes(hclog.NewNullLogger(), opts, []Component{
+		{
+			Name:  "api",
+			Paths: PathFilter{Include: []string{"services/api"}},
+			Start: day(1),
+			End:   day(5),
+		},
+		{
+			Name:  "web",
+			Paths: PathFilter{Include: []string{"services/web"}},
+			Start: day(1),
+			End:   day(20),
+		},
+	}, prs, nil, nil)
+	assert.NoError(t, err)
+
+	assert.Len(t, actual, 2)
+	assert.Equal(t, []int{3, 1}, noteNumbers(actual[0]))
+	assert.Equal(t, []int{3, 2}, noteNumbers(actual[1]))
+}
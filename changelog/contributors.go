@@ -0,0 +1,170 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+// Contributor is an author of one or more PRs in a changelog.
+type Contributor struct {
+	// Login is the GitHub username of the contributor
+	Login string `json:"login"`
+
+	// URL is the GitHub URL of the contributor
+	URL string `json:"url"`
+
+	// PRCount is the number of PRs in the changelog by the contributor
+	PRCount int `json:"pr_count"`
+
+	// FirstTime indicates the contributor had no merged PRs in the
+	// repository before the changelog, it is only set when first time
+	// contributor detection is enabled.
+	FirstTime bool `json:"first_time,omitempty"`
+}
+
+// contributorsFromNotes returns the deduplicated authors and co-authors of the
+// notes, sorted by login.
+func contributorsFromNotes(notes []ReleaseNote) []Contributor {
+	byLogin := map[string]*Contributor{}
+	prs := map[string]map[int]bool{}
+	for _, note := range notes {
+		authors := note.Authors
+		if len(authors) == 0 {
+			authors = []Author{{Login: note.Author, URL: note.AuthorURL}}
+		}
+
+		for _, a := range authors {
+			// co-authors that could not be matched to a GitHub user are
+			// not listed
+			if a.Login == "" {
+				continue
+			}
+			key := strings.ToLower(a.Login)
+			c, ok := byLogin[key]
+			if !ok {
+				c = &Contributor{
+					Login: a.Login,
+					URL:   a.URL,
+				}
+				byLogin[key] = c
+				prs[key] = map[int]bool{}
+			}
+			// a PR can have multiple notes, only count it once
+			if !prs[key][note.PRNumber] {
+				prs[key][note.PRNumber] = true
+				c.PRCount++
+			}
+		}
+	}
+
+	contributors := make([]Contributor, 0, len(byLogin))
+	for _, c := range byLogin {
+		contributors = append(contributors, *c)
+	}
+	sort.Slice(contributors, func(i, j int) bool {
+		return strings.ToLower(contributors[i].Login) < strings.ToLower(contributors[j].Login)
+	})
+	return contributors
+}
+
+// detectFirstTimeContributors sets FirstTime on the contributors that have no
+// PRs merged in the repository before the given time. PRs credited to a
+// contributor by an author override line, matched by patterns, are also taken
+// into account.
+func detectFirstTimeContributors(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	owner, repo string,
+	before time.Time,
+	patterns []*regexp.Regexp,
+	contributors []Contributor,
+) error {
+	for i, c := range contributors {
+		base := fmt.Sprintf("repo:%s/%s is:pr is:merged merged:<%s", owner, repo, before.UTC().Format(time.RFC3339))
+
+		var q struct {
+			Authored struct {
+				IssueCount int
+			} `graphql:"search(query: $query, type: ISSUE, first: 1)"`
+		}
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"query": githubv4.String(fmt.Sprintf("%s author:%s", base, c.Login)),
+		})
+		if err != nil {
+			return fmt.Errorf("error searching PRs by %s: %w", c.Login, err)
+		}
+
+		credited := false
+		if q.Authored.IssueCount == 0 {
+			credited, err = creditedBefore(ctx, client, fmt.Sprintf(`%s in:body "@%s"`, base, c.Login), patterns, c.Login)
+			if err != nil {
+				return fmt.Errorf("error searching PRs crediting %s: %w", c.Login, err)
+			}
+		}
+
+		contributors[i].FirstTime = q.Authored.IssueCount == 0 && !credited
+		if contributors[i].FirstTime {
+			logger.Debug("first time contributor", "login", c.Login)
+		}
+	}
+
+	return nil
+}
+
+// creditedBefore pages through the PRs found by the search query, which can
+// only match a mention of the login, and returns true if the body of any of
+// them has an author override line crediting login.
+func creditedBefore(ctx context.Context, client *githubv4.Client, query string, patterns []*regexp.Regexp, login string) (bool, error) {
+	var after *githubv4.String
+	for {
+		var q struct {
+			Search struct {
+				Nodes []struct {
+					PullRequest struct {
+						Body string
+					} `graphql:"... on PullRequest"`
+				}
+				PageInfo struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+			} `graphql:"search(query: $query, type: ISSUE, first: 100, after: $after)"`
+		}
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"query": githubv4.String(query),
+			"after": after,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		for _, n := range q.Search.Nodes {
+			if creditsLogin(n.PullRequest.Body, patterns, login) {
+				return true, nil
+			}
+		}
+		if !q.Search.PageInfo.HasNextPage {
+			return false, nil
+		}
+		after = &q.Search.PageInfo.EndCursor
+	}
+}
+
+// creditsLogin returns true if an author override line in the body credits
+// login.
+func creditsLogin(body string, patterns []*regexp.Regexp, login string) bool {
+	for _, l := range overrideAuthorsFromPR(body, patterns) {
+		if strings.EqualFold(l, login) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContributorsFromNotes(t *testing.T) {
+	actual := contributorsFromNotes([]ReleaseNote{
+		{Author: "foo", AuthorURL: "https://github.com/foo", PRNumber: 1},
+		// multiple notes in a single PR
+		{Author: "foo", AuthorURL: "https://github.com/foo", PRNumber: 1},
+		{Author: "Foo", AuthorURL: "https://github.com/Foo", PRNumber: 2},
+		{Author: "bar", AuthorURL: "https://github.com/bar", PRNumber: 3},
+		{Author: "", PRNumber: 4},
+		{Author: "bar", PRNumber: 5, Authors: []Author{
+			{Login: "bar", URL: "https://github.com/bar"},
+			{Login: "baz", URL: "https://github.com/baz"},
+			{Name: "Qux"},
+		}},
+	})
+
+	assert.Equal(t, []Contributor{
+		{Login: "bar", URL: "https://github.com/bar", PRCount: 2},
+		{Login: "baz", URL: "https://github.com/baz", PRCount: 1},
+		{Login: "foo", URL: "https://github.com/foo", PRCount: 2},
+	}, actual)
+}
+
+func TestCreditsLogin(t *testing.T) {
+	patterns := authorPatterns([]string{"Reported-by:"})
+	for i, c := range []struct {
+		expected bool
+		body     string
+	}{
+		{true, "Original Author: @foo"},
+		{true, "Contributed-by: @Foo"},
+		{true, "/author @foo"},
+		{true, "Reported-by: @foo"},
+		{false, "thanks @foo"},
+		{false, "Original Author: @foobar"},
+		{false, "Reviewed-by: @foo"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.body), func(t *testing.T) {
+			assert.Equal(t, c.expected, creditsLogin(c.body, patterns, "foo"))
+		})
+	}
+}
+
+func TestCreditedBefore(t *testing.T) {
+	// the crediting PR is on the second page of mentions
+	pages := [][]string{
+		{"thanks @foo", "cc @foo"},
+		{"Contributed-by: @foo"},
+	}
+	var cursors []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{}
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		cursors = append(cursors, req.Variables["after"])
+
+		page := len(cursors) - 1
+		var nodes []interface{}
+		for _, body := range pages[page] {
+			nodes = append(nodes, map[string]interface{}{"body": body})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"search": map[string]interface{}{
+				"nodes": nodes,
+				"pageInfo": map[string]interface{}{
+					"endCursor":   fmt.Sprintf("page%d", page),
+					"hasNextPage": page < len(pages)-1,
+				},
+			},
+		}})
+	}))
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	credited, err := creditedBefore(context.Background(), client, "query", authorPatterns(nil), "foo")
+	assert.NoError(t, err)
+	assert.True(t, credited)
+	assert.Equal(t, []interface{}{nil, "page0"}, cursors)
+}
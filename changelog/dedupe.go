@@ -0,0 +1,168 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+var (
+	// releasedHeadingRE matches the level 2 headings that start the section
+	// of each release in a changelog.
+	releasedHeadingRE = regexp.MustCompile(`^##\s+(.*)$`)
+
+	// listItemRE matches the markdown list items of a changelog section.
+	listItemRE = regexp.MustCompile(`^\s*[*+-]\s+(.*)$`)
+
+	// pullURLRE matches GitHub PR URLs.
+	pullURLRE = regexp.MustCompile(`https://github\.com/[\w.-]+/[\w.-]+/pull/[0-9]+`)
+
+	// itemPrefixRE matches a bold prefix of a list item, like the labels
+	// written by the default template.
+	itemPrefixRE = regexp.MustCompile(`^\*\*[^*]+:\*\*\s*`)
+
+	// itemSuffixRE matches a parenthesized group at the end of a list item,
+	// and itemCreditRE the PR link or author credit, like
+	// "([123](url) by [login](url))" or "(#123)", that make it a suffix
+	// rather than part of the text.
+	itemSuffixRE = regexp.MustCompile(`\s*\(((?:[^()]|\([^()]*\))*)\)\s*$`)
+	itemCreditRE = regexp.MustCompile(`/pull/[0-9]+|#[0-9]+|\bby\b`)
+
+	// itemAuthorRE matches an author credit ending a list item, like
+	// "by @login".
+	itemAuthorRE = regexp.MustCompile(`\s+by\s+(?:@[\w-]+|\[[^\]]*\]\([^)]*\))\s*$`)
+)
+
+// normalizeText returns the text lower cased with punctuation removed and
+// whitespace collapsed, so notes differing only in formatting compare equal.
+func normalizeText(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+// dedupeReleaseNotes drops notes of backport PRs whose original PR is also in
+// the notes, and notes with the same type and normalized text as a note
+// earlier in the slice. The notes are expected to be sorted, so the most
+// recently merged of a revert and reland pair is kept.
+func dedupeReleaseNotes(logger hclog.Logger, notes []ReleaseNote) []ReleaseNote {
+	numbers := map[int]bool{}
+	for _, n := range notes {
+		numbers[n.PRNumber] = true
+	}
+
+	texts := map[string]int{}
+	deduped := notes[:0]
+	for _, n := range notes {
+		original := 0
+		for _, o := range n.OriginalPRNumbers {
+			if o != n.PRNumber && numbers[o] {
+				original = o
+				break
+			}
+		}
+		if original != 0 {
+			logger.Debug("skipping duplicate note", "pr", n.PRNumber, "reason", "backport", "original", original)
+			continue
+		}
+
+		key := n.Type + "\x00" + normalizeText(n.Text)
+		if pr, ok := texts[key]; ok {
+			logger.Debug("skipping duplicate note", "pr", n.PRNumber, "reason", "same text", "duplicate_of", pr)
+			continue
+		}
+		texts[key] = n.PRNumber
+		deduped = append(deduped, n)
+	}
+	return deduped
+}
+
+// releasedNotes are the PRs and note text of the released sections of an
+// existing changelog.
+type releasedNotes struct {
+	prURLs map[string]bool
+	items  map[string]bool
+}
+
+// parseReleasedNotes returns the list items of the released sections of the
+// markdown changelog text. A release section starts with a level 2 heading,
+// sections for unreleased changes or for version itself (when regenerating a
+// release) are ignored.
+func parseReleasedNotes(text, version string) releasedNotes {
+	var versionRE *regexp.Regexp
+	if v := strings.TrimPrefix(version, "v"); v != "" {
+		versionRE = regexp.MustCompile(fmt.Sprintf(`(?:^|[^\w.-])v?%s(?:$|[^\w.-])`, regexp.QuoteMeta(v)))
+	}
+
+	released := releasedNotes{prURLs: map[string]bool{}, items: map[string]bool{}}
+	inRelease := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			inRelease = false
+			continue
+		}
+		if match := releasedHeadingRE.FindStringSubmatch(line); match != nil {
+			heading := match[1]
+			inRelease = !strings.Contains(strings.ToLower(heading), "unreleased") &&
+				(versionRE == nil || !versionRE.MatchString(heading))
+			continue
+		}
+		if !inRelease {
+			continue
+		}
+
+		match := listItemRE.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		for _, u := range pullURLRE.FindAllString(match[1], -1) {
+			released.prURLs[strings.ToLower(u)] = true
+		}
+		released.items[normalizeText(itemText(match[1]))] = true
+	}
+	return released
+}
+
+// itemText returns the note text of a list item, without the prefix, PR link
+// and author credit around it.
+func itemText(item string) string {
+	item = itemPrefixRE.ReplaceAllString(item, "")
+	if match := itemSuffixRE.FindStringSubmatchIndex(item); match != nil && itemCreditRE.MatchString(item[match[2]:match[3]]) {
+		item = item[:match[0]]
+	}
+	return itemAuthorRE.ReplaceAllString(item, "")
+}
+
+// contains returns true if the note's PR, or the PR that backported it, is
+// linked from a released list item, or the text of a released list item is
+// the note text.
+func (r releasedNotes) contains(note ReleaseNote) bool {
+	for _, u := range []string{note.PRURL, note.BackportPRURL} {
+		if u != "" && r.prURLs[strings.ToLower(u)] {
+			return true
+		}
+	}
+
+	text := normalizeText(note.Text)
+	return text != "" && r.items[text]
+}
+
+// excludeReleasedNotes drops the notes already present in a released section
+// of the previous changelog text.
+func excludeReleasedNotes(logger hclog.Logger, notes []ReleaseNote, previous, version string) []ReleaseNote {
+	released := parseReleasedNotes(previous, version)
+
+	var unreleased []ReleaseNote
+	for _, n := range notes {
+		if released.contains(n) {
+			logger.Info("skipping previously released note", "pr", n.PRNumber, "url", n.PRURL)
+			continue
+		}
+		unreleased = append(unreleased, n)
+	}
+	return unreleased
+}
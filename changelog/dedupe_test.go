@@ -0,0 +1,100 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeText(t *testing.T) {
+	for i, c := range []struct {
+		expected string
+		text     string
+	}{
+		{"", ""},
+		{"fix foo", "Fix foo"},
+		{"fix foo", "  Fix   `foo`.\n"},
+		{"add foo bar 2", "**Add** foo-bar (2)!"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.text), func(t *testing.T) {
+			assert.Equal(t, c.expected, normalizeText(c.text))
+		})
+	}
+}
+
+func TestDedupeReleaseNotes(t *testing.T) {
+	notes := []ReleaseNote{
+		{PRNumber: 6, Text: "Add foo."},
+		{PRNumber: 5, Text: "Fix bar", OriginalPRNumbers: []int{2}},
+		{PRNumber: 4, Text: "Fix baz", OriginalPRNumbers: []int{7}},
+		{PRNumber: 3, Text: "Revert \"Add foo\""},
+		{PRNumber: 2, Text: "Fix bar on Linux"},
+		{PRNumber: 1, Text: "add `foo`"},
+		// the same text with another type is not a duplicate
+		{PRNumber: 0, Text: "Fix bar on Linux", Type: "bug"},
+	}
+
+	numbers := noteNumbers(dedupeReleaseNotes(hclog.NewNullLogger(), notes))
+	// 5 is a backport of 2, 4 is a backport of a PR not in the notes and 1
+	// was reverted and relanded in 6
+	assert.Equal(t, []int{6, 4, 3, 2, 0}, numbers)
+}
+
+func TestItemText(t *testing.T) {
+	for i, c := range []struct {
+		expected string
+		item     string
+	}{
+		{"Fix foo", "Fix foo"},
+		{"Fix foo", "Fix foo ([1](https://github.com/foo/bar/pull/1) by [foo](https://github.com/foo))"},
+		{"Fix foo", "**service/api:** Fix foo ([1](https://github.com/foo/bar/pull/1))"},
+		{"Fix foo", "Fix foo (#1)"},
+		{"Fix foo", "Fix foo by @foo"},
+		{"Fix foo (on Linux)", "Fix foo (on Linux)"},
+		{"Released by text", "Released by text"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.item), func(t *testing.T) {
+			assert.Equal(t, c.expected, itemText(c.item))
+		})
+	}
+}
+
+func TestExcludeReleasedNotes(t *testing.T) {
+	previous := `# Changelog
+
+* Not in a release
+
+## Unreleased
+
+* Unreleased note ([1](https://github.com/foo/bar/pull/1) by [foo](https://github.com/foo))
+
+## 1.1.0 (March 1, 2020)
+
+* Regenerated note ([2](https://github.com/foo/bar/pull/2) by [foo](https://github.com/foo))
+
+## 1.0.0 (February 1, 2020)
+
+### Bug Fixes
+
+* **service/api:** Fix the ` + "`foo`" + ` endpoint ([10](https://github.com/foo/bar/pull/10) by [foo](https://github.com/foo))
+- Released by text
+`
+
+	notes := []ReleaseNote{
+		{PRNumber: 1, PRURL: "https://github.com/foo/bar/pull/1", Text: "Unreleased note"},
+		{PRNumber: 2, PRURL: "https://github.com/foo/bar/pull/2", Text: "Regenerated note"},
+		{PRNumber: 3, PRURL: "https://github.com/foo/bar/pull/3", Text: "Not in a release"},
+		{PRNumber: 10, PRURL: "https://github.com/foo/bar/pull/10", Text: "Fix the endpoint"},
+		{PRNumber: 11, PRURL: "https://github.com/foo/bar/pull/11", Text: "Fix the foo endpoint."},
+		{PRNumber: 12, PRURL: "https://github.com/foo/bar/pull/12", Text: "released by text"},
+		{PRNumber: 14, PRURL: "https://github.com/foo/bar/pull/14", Text: "Backported", BackportPRURL: "https://github.com/foo/bar/pull/10"},
+		{PRNumber: 15, PRURL: "https://github.com/foo/bar/pull/15", Text: "Fix the foo end"},
+		// part of a released item is not the same note
+		{PRNumber: 16, PRURL: "https://github.com/foo/bar/pull/16", Text: "the foo endpoint"},
+	}
+
+	actual := excludeReleasedNotes(hclog.NewNullLogger(), notes, previous, "v1.1.0")
+	assert.Equal(t, []int{1, 2, 3, 15, 16}, noteNumbers(actual))
+}
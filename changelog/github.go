@@ -0,0 +1,410 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+// maxNodeIDs is the maximum number of IDs GitHub accepts in a single nodes
+// query.
+const maxNodeIDs = 100
+
+// commit is a commit in the branch history.
+type commit struct {
+	ID            string
+	OID           string
+	CommittedDate time.Time
+	Message       string
+}
+
+// associatedPullRequest is a PR associated with a commit in the branch
+// history. It is cached by commit, so it only has fields that do not change
+// once the PR is merged, anything else is read from the fetched pullRequest.
+type associatedPullRequest struct {
+	BaseRef struct {
+		Repository struct {
+			Owner struct {
+				Login string
+			}
+			Name string
+		}
+
+		Name string
+	}
+	State  githubv4.PullRequestState
+	ID     string
+	Number int
+}
+
+// pullRequest is the PR data used to build release notes.
+type pullRequest struct {
+	MergedAt  time.Time
+	UpdatedAt time.Time
+	ID        string
+	Number    int
+	Title     string
+	Body      string
+	URL       string
+	Author    struct {
+		Typename string `graphql:"__typename"`
+		Login    string
+		URL      string
+	}
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 100)"`
+	Files   pullRequestFiles `graphql:"files(first: 100) @include(if: $withFiles)"`
+	Commits struct {
+		Nodes []pullRequestCommit
+	} `graphql:"commits(first: 100)"`
+	MergeCommit struct {
+		OID            string
+		AbbreviatedOID string
+	}
+	Milestone struct {
+		Title string
+	}
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	Reviews      struct {
+		Nodes []struct {
+			Author struct {
+				Login string
+				URL   string
+			}
+		}
+	} `graphql:"reviews(first: 100, states: APPROVED)"`
+	ClosingIssuesReferences struct {
+		Nodes []closingIssue
+	} `graphql:"closingIssuesReferences(first: 25)"`
+}
+
+// closingIssue is an issue GitHub will close, or has closed, when the PR is
+// merged.
+type closingIssue struct {
+	Number     int
+	Title      string
+	URL        string
+	Repository struct {
+		Owner struct {
+			Login string
+		}
+		Name string
+	}
+}
+
+type pullRequestCommit struct {
+	Commit struct {
+		OID     string
+		Message string
+		Author  gitActor
+	}
+}
+
+// gitActor is the author or committer of a commit, User is set if the email
+// matches a GitHub user.
+type gitActor struct {
+	Email string
+	User  *user
+}
+
+type user struct {
+	Login string
+	URL   string
+}
+
+type pullRequestFiles struct {
+	Nodes []struct {
+		Path string
+	}
+	PageInfo struct {
+		EndCursor   string
+		HasNextPage bool
+	}
+}
+
+// labels returns the names of the PR's labels.
+func (pr pullRequest) labels() []string {
+	labels := make([]string, 0, len(pr.Labels.Nodes))
+	for _, l := range pr.Labels.Nodes {
+		labels = append(labels, l.Name)
+	}
+	return labels
+}
+
+// files returns the paths of the files changed by the PR.
+func (pr pullRequest) files() []string {
+	files := make([]string, 0, len(pr.Files.Nodes))
+	for _, n := range pr.Files.Nodes {
+		files = append(files, n.Path)
+	}
+	return files
+}
+
+// chunk splits ids into slices of at most size.
+func chunk(ids []string, size int) [][]string {
+	var chunks [][]string
+	for len(ids) > size {
+		chunks = append(chunks, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		chunks = append(chunks, ids)
+	}
+	return chunks
+}
+
+// listCommits returns the commits on the branch between start and end, most
+// recent first.
+func listCommits(
+	ctx context.Context,
+	client *githubv4.Client,
+	owner, repo, branch string,
+	start, end time.Time,
+) ([]commit, error) {
+	var q struct {
+		Repository struct {
+			Ref struct {
+				Target struct {
+					Commit struct {
+						History struct {
+							Nodes []commit
+						} `graphql:"history(since: $since, until: $until)"`
+					} `graphql:"... on Commit"`
+				}
+			} `graphql:"ref(qualifiedName: $ref)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(owner),
+		"repoName":  githubv4.String(repo),
+		"ref":       githubv4.String(fmt.Sprintf("refs/heads/%s", branch)),
+		"since":     githubv4.GitTimestamp{Time: start},
+		"until":     githubv4.GitTimestamp{Time: end},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return q.Repository.Ref.Target.Commit.History.Nodes, nil
+}
+
+// associatedPullRequests returns the PRs associated with each commit, keyed by
+// commit OID, using the cache where possible.
+func associatedPullRequests(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	cache *Cache,
+	owner, repo string,
+	commits []commit,
+) (map[string][]associatedPullRequest, error) {
+	result := make(map[string][]associatedPullRequest, len(commits))
+
+	var uncached []string
+	for _, c := range commits {
+		var prs []associatedPullRequest
+		if cache.get(&prs, cacheKindCommit, owner, repo, c.OID) {
+			result[c.OID] = prs
+			continue
+		}
+		uncached = append(uncached, c.ID)
+	}
+
+	if cache != nil {
+		logger.Debug("commit cache", "hits", len(commits)-len(uncached), "misses", len(uncached))
+	}
+
+	for _, ids := range chunk(uncached, maxNodeIDs) {
+		var q struct {
+			Nodes []struct {
+				Commit struct {
+					OID                    string
+					AssociatedPullRequests struct {
+						Nodes []associatedPullRequest
+					} `graphql:"associatedPullRequests(first: 100)"`
+				} `graphql:"... on Commit"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"ids": ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range q.Nodes {
+			prs := n.Commit.AssociatedPullRequests.Nodes
+			result[n.Commit.OID] = prs
+
+			// open PRs may still be merged or relabeled, so only cache
+			// associations that are settled
+			settled := true
+			for _, pr := range prs {
+				if pr.State == githubv4.PullRequestStateOpen {
+					settled = false
+					break
+				}
+			}
+			if !settled {
+				continue
+			}
+			if err := cache.put(prs, cacheKindCommit, owner, repo, n.Commit.OID); err != nil {
+				logger.Warn("unable to cache commit", "commit", n.Commit.OID, "err", err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// commitAssociatedPullRequests returns the PRs associated with a commit by
+// its SHA, which may be abbreviated. No PRs are returned for unknown commits.
+func commitAssociatedPullRequests(ctx context.Context, client *githubv4.Client, owner, repo, sha string) ([]associatedPullRequest, error) {
+	var q struct {
+		Repository struct {
+			Object *struct {
+				Commit struct {
+					AssociatedPullRequests struct {
+						Nodes []associatedPullRequest
+					} `graphql:"associatedPullRequests(first: 10)"`
+				} `graphql:"... on Commit"`
+			} `graphql:"object(expression: $commit)"`
+		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
+	}
+
+	err := client.Query(ctx, &q, map[string]interface{}{
+		"repoOwner": githubv4.String(owner),
+		"repoName":  githubv4.String(repo),
+		"commit":    githubv4.String(sha),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if q.Repository.Object == nil {
+		return nil, nil
+	}
+	return q.Repository.Object.Commit.AssociatedPullRequests.Nodes, nil
+}
+
+// fetchPullRequests returns the PRs with the given node IDs, using the cache
+// for PRs that have not been updated since they were cached. The changed
+// files are only fetched if withFiles is set.
+func fetchPullRequests(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	cache *Cache,
+	prIDs []string,
+	withFiles bool,
+) ([]pullRequest, error) {
+	var prs []pullRequest
+
+	// PRs cached without their files cannot be used when filtering by path
+	cacheKey := func(id string, updatedAt time.Time) []string {
+		key := []string{id, updatedAt.UTC().Format(time.RFC3339Nano)}
+		if withFiles {
+			key = append(key, "files")
+		}
+		return key
+	}
+
+	stale := prIDs
+	if cache != nil {
+		stale = nil
+		for _, ids := range chunk(prIDs, maxNodeIDs) {
+			var q struct {
+				Nodes []struct {
+					PullRequest struct {
+						ID        string
+						UpdatedAt time.Time
+					} `graphql:"... on PullRequest"`
+				} `graphql:"nodes(ids: $ids)"`
+			}
+
+			err := client.Query(ctx, &q, map[string]interface{}{
+				"ids": ids,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, n := range q.Nodes {
+				var pr pullRequest
+				if cache.get(&pr, cacheKindPullRequest, cacheKey(n.PullRequest.ID, n.PullRequest.UpdatedAt)...) {
+					prs = append(prs, pr)
+					continue
+				}
+				stale = append(stale, n.PullRequest.ID)
+			}
+		}
+
+		logger.Debug("pull request cache", "hits", len(prs), "misses", len(stale))
+	}
+
+	for _, ids := range chunk(stale, maxNodeIDs) {
+		var q struct {
+			Nodes []struct {
+				PullRequest pullRequest `graphql:"... on PullRequest"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"ids":       ids,
+			"withFiles": githubv4.Boolean(withFiles),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range q.Nodes {
+			pr := n.PullRequest
+			if err := fetchRemainingFiles(ctx, client, &pr); err != nil {
+				return nil, err
+			}
+			prs = append(prs, pr)
+
+			if err := cache.put(pr, cacheKindPullRequest, cacheKey(pr.ID, pr.UpdatedAt)...); err != nil {
+				logger.Warn("unable to cache pull request", "pr", pr.Number, "err", err)
+			}
+		}
+	}
+
+	return prs, nil
+}
+
+// fetchRemainingFiles pages through the changed files of PRs with more files
+// than were returned with the PR.
+func fetchRemainingFiles(ctx context.Context, client *githubv4.Client, pr *pullRequest) error {
+	for pr.Files.PageInfo.HasNextPage {
+		var q struct {
+			Node struct {
+				PullRequest struct {
+					Files pullRequestFiles `graphql:"files(first: 100, after: $after)"`
+				} `graphql:"... on PullRequest"`
+			} `graphql:"node(id: $id)"`
+		}
+
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"id":    githubv4.ID(pr.ID),
+			"after": githubv4.String(pr.Files.PageInfo.EndCursor),
+		})
+		if err != nil {
+			return err
+		}
+
+		files := q.Node.PullRequest.Files
+		pr.Files.Nodes = append(pr.Files.Nodes, files.Nodes...)
+		pr.Files.PageInfo = files.PageInfo
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk(t *testing.T) {
+	for i, c := range []struct {
+		expected [][]string
+		ids      []string
+	}{
+		{nil, nil},
+		{[][]string{{"a"}}, []string{"a"}},
+		{[][]string{{"a", "b"}}, []string{"a", "b"}},
+		{[][]string{{"a", "b"}, {"c"}}, []string{"a", "b", "c"}},
+		{[][]string{{"a", "b"}, {"c", "d"}}, []string{"a", "b", "c", "d"}},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, c.expected, chunk(c.ids, 2))
+		})
+	}
+}
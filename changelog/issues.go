@@ -0,0 +1,82 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Issue is an issue closed by the PR of a release note.
+type Issue struct {
+	// Owner and Repo are the repository of the issue, which may differ from
+	// the repository of the PR
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+
+	// Number is the number of the issue
+	Number int `json:"number"`
+
+	// Title is the title of the issue, it is only set for issues GitHub
+	// links to the PR as closing references
+	Title string `json:"title,omitempty"`
+
+	// URL is a URL to the issue
+	URL string `json:"url"`
+}
+
+// closingIssueRE matches GitHub closing keywords followed by an issue
+// reference, either "#123" or the cross-repository "org/repo#45".
+var closingIssueRE = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?[ \t]+(?:([A-Za-z0-9-]+)/([A-Za-z0-9_.-]+))?#([0-9]+)\b`)
+
+func issueURL(owner, repo string, number int) string {
+	return fmt.Sprintf("https://github.com/%s/%s/issues/%d", owner, repo, number)
+}
+
+// issuesFromPR returns the issues closed by the PR, starting with the closing
+// references GitHub knows about, followed by closing keyword mentions in the
+// body. Issue references without a repository are in owner/repo.
+func issuesFromPR(pr pullRequest, owner, repo string) []Issue {
+	var issues []Issue
+	seen := map[string]bool{}
+	add := func(issue Issue) {
+		key := strings.ToLower(fmt.Sprintf("%s/%s#%d", issue.Owner, issue.Repo, issue.Number))
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		issues = append(issues, issue)
+	}
+
+	for _, n := range pr.ClosingIssuesReferences.Nodes {
+		add(Issue{
+			Owner:  n.Repository.Owner.Login,
+			Repo:   n.Repository.Name,
+			Number: n.Number,
+			Title:  n.Title,
+			URL:    n.URL,
+		})
+	}
+
+	for _, match := range closingIssueRE.FindAllStringSubmatch(pr.Body, -1) {
+		issueOwner, issueRepo := match[1], match[2]
+		if issueOwner == "" {
+			if owner == "" || repo == "" {
+				continue
+			}
+			issueOwner, issueRepo = owner, repo
+		}
+		number, err := strconv.Atoi(match[3])
+		if err != nil || number == 0 {
+			continue
+		}
+		add(Issue{
+			Owner:  issueOwner,
+			Repo:   issueRepo,
+			Number: number,
+			URL:    issueURL(issueOwner, issueRepo, number),
+		})
+	}
+
+	return issues
+}
@@ -0,0 +1,27 @@
+package changelog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssuesFromPR(t *testing.T) {
+	var pr pullRequest
+	pr.Body = "Fixes #12, closes other/thing#3\n\nThis is related to #99 and resolves: #7.\nfixes #12\nFixed Other/Thing#3"
+	pr.ClosingIssuesReferences.Nodes = make([]closingIssue, 1)
+	pr.ClosingIssuesReferences.Nodes[0].Number = 7
+	pr.ClosingIssuesReferences.Nodes[0].Title = "Something is broken"
+	pr.ClosingIssuesReferences.Nodes[0].URL = "https://github.com/foo/bar/issues/7"
+	pr.ClosingIssuesReferences.Nodes[0].Repository.Owner.Login = "foo"
+	pr.ClosingIssuesReferences.Nodes[0].Repository.Name = "bar"
+
+	assert.Equal(t, []Issue{
+		{Owner: "foo", Repo: "bar", Number: 7, Title: "Something is broken", URL: "https://github.com/foo/bar/issues/7"},
+		{Owner: "foo", Repo: "bar", Number: 12, URL: "https://github.com/foo/bar/issues/12"},
+		{Owner: "other", Repo: "thing", Number: 3, URL: "https://github.com/other/thing/issues/3"},
+	}, issuesFromPR(pr, "foo", "bar"))
+
+	pr = pullRequest{Body: "Prefix#1 fixes nothing"}
+	assert.Empty(t, issuesFromPR(pr, "foo", "bar"))
+}
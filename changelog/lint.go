@@ -0,0 +1,133 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// typeNone is the release note type used to indicate a PR intentionally has no
+// release note. It is always accepted by LintReleaseNotes.
+const typeNone = "none"
+
+// Diagnostic is a single problem found in a PR body by LintReleaseNotes.
+type Diagnostic struct {
+	// Line is the 1-based line in the body the problem was found on, or 0 if
+	// the problem applies to the body as a whole.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return d.Message
+	}
+	return fmt.Sprintf("line %d: %s", d.Line, d.Message)
+}
+
+var (
+	// lintFenceRE matches any line that looks like it is opening a release
+	// note block, even if ReleaseNoteBlocks would not recognize it.
+	lintFenceRE = regexp.MustCompile("(?i)^(\\s*)```(\\s*)(release[-_ ]?notes?.*)$")
+
+	// lintInfoRE matches the fence info strings ReleaseNoteBlocks accepts.
+	lintInfoRE = regexp.MustCompile(`^release-?notes?(?::(.*)|\s+(.*))?$`)
+)
+
+// LintReleaseNotes checks a PR body for problems with its release note blocks,
+// including blocks that ReleaseNoteBlocks would silently ignore. If
+// allowedTypes is not empty, typed blocks must use one of those types (the
+// "none" type is always allowed). A nil result means the body is valid.
+func LintReleaseNotes(body string, allowedTypes []string) []Diagnostic {
+	var diags []Diagnostic
+	addDiag := func(line int, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Line:    line,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	lines := strings.Split(body, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+
+	blocks := 0
+	for i := 0; i < len(lines); i++ {
+		match := lintFenceRE.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		blocks++
+		lineNo := i + 1
+		indent, space, info := match[1], match[2], strings.TrimSpace(match[3])
+
+		end := -1
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "```" {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			addDiag(lineNo, "release note block is not closed")
+			break
+		}
+		content := lines[i+1 : end]
+		i = end
+
+		if indent != "" {
+			addDiag(lineNo, "release note block must not be indented, it will be ignored")
+		}
+		if space != "" {
+			addDiag(lineNo, "release note block must not have whitespace after the opening backticks, it will be ignored")
+		}
+
+		infoMatch := lintInfoRE.FindStringSubmatch(info)
+		if infoMatch == nil {
+			addDiag(lineNo, "unrecognized release note block %q, expected \"```release-note\" or \"```release-note:<type>\"", info)
+			continue
+		}
+		typ, _, invalid := parseFenceInfo(infoMatch[1])
+		if infoMatch[2] != "" {
+			var untyped string
+			untyped, _, invalid = parseFenceInfo(infoMatch[2])
+			if untyped != "" {
+				invalid = append([]string{untyped}, invalid...)
+			}
+		}
+		for _, attr := range invalid {
+			addDiag(lineNo, "invalid release note attribute %q, expected key=value", attr)
+		}
+
+		if len(content) > 1 {
+			addDiag(lineNo+1, "release note must be a single line directly after the opening fence, it will be ignored")
+			continue
+		}
+
+		if typ != "" && typ != typeNone && len(allowedTypes) > 0 && !stringInSlice(allowedTypes, typ) {
+			addDiag(lineNo, "unknown release note type %q, expected one of: %s", typ, strings.Join(allowedTypes, ", "))
+		}
+
+		text := ""
+		if len(content) == 1 {
+			text = strings.TrimSpace(stripMarkdownBullet(content[0]))
+		}
+		if text == "" && typ != typeNone {
+			addDiag(lineNo, "release note is empty")
+		}
+	}
+
+	if blocks == 0 {
+		addDiag(0, "no release note block found")
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Line < diags[j].Line
+	})
+
+	return diags
+}
@@ -0,0 +1,56 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintReleaseNotes(t *testing.T) {
+	allowed := []string{"bug", "enhancement"}
+
+	for i, c := range []struct {
+		expected []Diagnostic
+		body     string
+	}{
+		// valid
+		{nil, "```release-note\nfoo\n```"},
+		{nil, "```releasenote:bug\nfoo\n```"},
+		{nil, "```release-note:none\n```"},
+		{nil, "```release-note:none\n\n```"},
+		{nil, "intro\r\n\r\n```release-note:enhancement\r\nfoo\r\n```\r\n"},
+		{nil, "```release-note:bug\nfoo\n```\n\n```release-note:enhancement\nbar\n```"},
+		{nil, "```release-note:bug scope=ec2 docs=\"/guides/x y\"\nfoo\n```"},
+		{nil, "```release-note scope=ec2\nfoo\n```"},
+
+		{[]Diagnostic{{0, "no release note block found"}}, ""},
+		{[]Diagnostic{{0, "no release note block found"}}, "```go\nfoo\n```"},
+
+		{[]Diagnostic{{2, "release note block must not be indented, it will be ignored"}},
+			"\n ```releasenote\nfoo\n```"},
+		{[]Diagnostic{{1, "release note block must not have whitespace after the opening backticks, it will be ignored"}},
+			"``` release-note\nfoo\n```"},
+		{[]Diagnostic{{1, "release note block is not closed"}},
+			"```release-note\nfoo"},
+		{[]Diagnostic{{1, `unknown release note type "feature", expected one of: bug, enhancement`}},
+			"```release-note:feature\nfoo\n```"},
+		{[]Diagnostic{{1, `invalid release note attribute "upgrade", expected key=value`}},
+			"```release-note:bug scope=ec2 upgrade\nfoo\n```"},
+		{[]Diagnostic{{1, `invalid release note attribute "bug", expected key=value`}},
+			"```release-note bug\nfoo\n```"},
+		{[]Diagnostic{{1, "release note is empty"}},
+			"```release-note\n\n```"},
+		{[]Diagnostic{{1, "release note is empty"}},
+			"```release-note:bug\n```"},
+		{[]Diagnostic{{2, "release note must be a single line directly after the opening fence, it will be ignored"}},
+			"```release-note:bug\nfoo\nbar\n```"},
+		{[]Diagnostic{{3, `unrecognized release note block "release_note:bug", expected "` + "```release-note" + `" or "` + "```release-note:<type>" + `"`}},
+			"foo\n\n```release_note:bug\nfoo\n```"},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			actual := LintReleaseNotes(c.body, allowed)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package changelog
+
+import (
+	"path"
+	"strings"
+)
+
+// PathFilter selects PRs by the files they change. Patterns use path.Match
+// syntax with the addition of "**" to match any number of directories, and
+// match a file if they match its path or any of its parent directories, so
+// "services/api" matches every file under that directory.
+type PathFilter struct {
+	// Include patterns, a PR must change at least one matching file. If empty
+	// all files are included.
+	Include []string
+
+	// Exclude patterns, files matching these are ignored even if included.
+	Exclude []string
+}
+
+// IsZero returns true if the filter has no patterns and matches everything.
+func (f PathFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Match returns true if any of the files is included and not excluded.
+func (f PathFilter) Match(files []string) bool {
+	if f.IsZero() {
+		return true
+	}
+
+	for _, file := range files {
+		if len(f.Include) > 0 && !matchAnyPath(f.Include, file) {
+			continue
+		}
+		if matchAnyPath(f.Exclude, file) {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// matchAllFilters returns true if any of the files is matched by every one
+// of the filters.
+func matchAllFilters(filters []PathFilter, files []string) bool {
+	zero := true
+	for _, f := range filters {
+		if !f.IsZero() {
+			zero = false
+		}
+	}
+	if zero {
+		return true
+	}
+
+	for _, file := range files {
+		matched := true
+		for _, f := range filters {
+			if !f.Match([]string{file}) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyPath(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches a slash separated file path against the pattern, see
+// PathFilter.
+func matchPath(pattern, file string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	fileParts := strings.Split(strings.Trim(file, "/"), "/")
+
+	// try the file and each of its parent directories
+	for i := len(fileParts); i > 0; i-- {
+		if matchParts(patternParts, fileParts[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], parts[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
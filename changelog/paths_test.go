@@ -0,0 +1,69 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPath(t *testing.T) {
+	for i, c := range []struct {
+		expected bool
+		pattern  string
+		file     string
+	}{
+		{true, "main.go", "main.go"},
+		{false, "main.go", "cmd/main.go"},
+		{true, "*.go", "main.go"},
+
+		// parent directories
+		{true, "services/api", "services/api/main.go"},
+		{true, "services/api/", "services/api/handlers/users.go"},
+		{false, "services/api", "services/apiv2/main.go"},
+		{true, "services/*", "services/api/main.go"},
+
+		// double star
+		{true, "**/*.go", "main.go"},
+		{true, "**/*.go", "services/api/main.go"},
+		{false, "**/*.go", "README.md"},
+		{true, "services/**/testdata", "services/api/handlers/testdata/foo.json"},
+		{true, "services/**", "services/api/main.go"},
+		{false, "services/**", "docs/index.md"},
+
+		// invalid patterns never match
+		{false, "[", "["},
+	} {
+		t.Run(fmt.Sprintf("%d %s %s", i, c.pattern, c.file), func(t *testing.T) {
+			assert.Equal(t, c.expected, matchPath(c.pattern, c.file))
+		})
+	}
+}
+
+func TestPathFilter_Match(t *testing.T) {
+	f := PathFilter{
+		Include: []string{"services/api"},
+		Exclude: []string{"**/*_test.go", "services/api/docs"},
+	}
+
+	for i, c := range []struct {
+		expected bool
+		files    []string
+	}{
+		{false, nil},
+		{false, []string{"services/web/main.go"}},
+		{true, []string{"services/api/main.go"}},
+		{true, []string{"services/web/main.go", "services/api/main.go"}},
+		{false, []string{"services/api/main_test.go"}},
+		{false, []string{"services/api/docs/index.md", "services/api/main_test.go"}},
+		{true, []string{"services/api/docs/index.md", "services/api/main.go"}},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, c.expected, f.Match(c.files))
+		})
+	}
+
+	assert.True(t, PathFilter{}.Match(nil))
+	assert.True(t, PathFilter{Exclude: []string{"docs"}}.Match([]string{"main.go"}))
+	assert.False(t, PathFilter{Exclude: []string{"docs"}}.Match([]string{"docs/index.md"}))
+}
@@ -32,6 +32,10 @@ type ReleaseNote struct {
 	// AuthorURL is the GitHub URL of the commit author
 	AuthorURL string `json:"author_url"`
 
+	// Authors is everyone credited for the note, starting with Author and
+	// followed by any co-authors
+	Authors []Author `json:"authors,omitempty"`
+
 	//PRDate is the Date the PR was merged
 	PRDate time.Time `json:"pr_date"`
 
@@ -42,7 +46,7 @@ type ReleaseNote struct {
 	PRNumber int `json:"pr_number"`
 
 	// Labels is a list of all the labels on the PR.
-	Labels []string `labels:"areas,omitempty"`
+	Labels []string `json:"labels,omitempty"`
 
 	// Indicates whether or not a note will appear as a bug (`bug` label).
 	Bug bool `json:"bug,omitempty"`
@@ -52,7 +56,97 @@ type ReleaseNote struct {
 	BreakingChange bool `json:"breaking_change,omitempty"`
 
 	// Type is the type of entry the ReleaseNote is
-	Type string
+	Type string `json:"type,omitempty"`
+
+	// Metadata are the key=value attributes of the note's release note
+	// block, like scope=ec2
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// BlockIndex is the position of the note's block among the release note
+	// blocks of the PR body, starting at 0
+	BlockIndex int `json:"block_index,omitempty"`
+
+	// Issues are the issues closed by the PR
+	Issues []Issue `json:"issues,omitempty"`
+
+	// Backport indicates the PR was merged into another branch and its
+	// changes were backported to the branch of the changelog, either by the
+	// backport PR BackportPRNumber or by cherry-picking commits directly.
+	// PRDate is when the backport was merged.
+	Backport         bool   `json:"backport,omitempty"`
+	BackportPRNumber int    `json:"backport_pr_number,omitempty"`
+	BackportPRURL    string `json:"backport_pr_url,omitempty"`
+
+	// Milestone is the title of the PR's milestone
+	Milestone string `json:"milestone,omitempty"`
+
+	// MergeCommitOID is the SHA of the commit the PR was merged with, and
+	// MergeCommitAbbreviatedOID is its short form
+	MergeCommitOID            string `json:"merge_commit_oid,omitempty"`
+	MergeCommitAbbreviatedOID string `json:"merge_commit_abbreviated_oid,omitempty"`
+
+	// Reviewers are the users who approved the PR
+	Reviewers []Author `json:"reviewers,omitempty"`
+
+	// Additions and Deletions are the lines changed by the PR, and
+	// ChangedFiles the number of files
+	Additions    int `json:"additions,omitempty"`
+	Deletions    int `json:"deletions,omitempty"`
+	ChangedFiles int `json:"changed_files,omitempty"`
+
+	// Revert indicates the PR reverts an earlier PR, RevertedPRNumber and
+	// RevertedPRURL are set if the reverted PR is known. Reverts of PRs in
+	// the same changelog are dropped along with the PR they revert.
+	Revert           bool   `json:"revert,omitempty"`
+	RevertedPRNumber int    `json:"reverted_pr_number,omitempty"`
+	RevertedPRURL    string `json:"reverted_pr_url,omitempty"`
+
+	// OriginalPRNumbers are the PRs the PR says it backports, its note is
+	// dropped as a duplicate if any of them are also in the changelog
+	OriginalPRNumbers []int `json:"original_pr_numbers,omitempty"`
+}
+
+// TypeLabel maps a PR label to a release note type. It is used to assign a
+// type to release notes that did not specify one in their block. A Label
+// ending in "*" matches any label with that prefix, and if Type is empty the
+// remainder of the matched label is used as the type (so "type/*" maps
+// "type/enhancement" to "enhancement").
+type TypeLabel struct {
+	Label string
+	Type  string
+}
+
+// match returns the type for label if the rule applies to it.
+func (tl TypeLabel) match(label string) (string, bool) {
+	if strings.HasSuffix(tl.Label, "*") {
+		prefix := strings.TrimSuffix(tl.Label, "*")
+		if !strings.HasPrefix(label, prefix) {
+			return "", false
+		}
+		if tl.Type == "" {
+			suffix := strings.TrimPrefix(label, prefix)
+			return suffix, suffix != ""
+		}
+		return tl.Type, true
+	}
+	if label != tl.Label || tl.Type == "" {
+		return "", false
+	}
+	return tl.Type, true
+}
+
+// typeFromLabels returns the type of the first rule that matches any of the
+// labels. Rules are checked in order, so earlier rules take precedence when
+// multiple labels match.
+func typeFromLabels(rules []TypeLabel, labels []string) string {
+	for _, rule := range rules {
+		for _, label := range labels {
+			if typ, ok := rule.match(label); ok {
+				return typ
+			}
+		}
+	}
+	return ""
 }
 
 // ReleaseNoteEntry is a struct containing the type of entry and the body of
@@ -61,103 +155,55 @@ type ReleaseNote struct {
 type ReleaseNoteEntry struct {
 	Type string
 	Text string
+
+	// Metadata are the key=value attributes of the block's fence info
+	// string, like "```release-note:bug scope=ec2"
+	Metadata map[string]string
 }
 
-func listPullRequestIDs(
+// commitPullRequestIDs returns the IDs of the merged PRs into the branch
+// associated with the commits. No note labels are not checked, as labels can
+// change after the associations are cached, see dropNoNoteLabels.
+func commitPullRequestIDs(
 	ctx context.Context,
 	client *githubv4.Client,
 	logger hclog.Logger,
-	owner, repo, branch string, noNoteLabels []string,
-	start, end time.Time,
+	opts Options,
+	commits []commit,
 ) ([]string, error) {
-	var q struct {
-		Repository struct {
-			Ref struct {
-				Target struct {
-					Commit struct {
-						History struct {
-							Nodes []struct {
-								OID string
-
-								AssociatedPullRequests struct {
-									Nodes []struct {
-										Labels struct {
-											Nodes []struct {
-												Name string
-											}
-										} `graphql:"labels(first: 100)"`
-										BaseRef struct {
-											Repository struct {
-												Owner struct {
-													Login string
-												}
-												Name string
-											}
-
-											Name string
-										}
-										State  githubv4.PullRequestState
-										ID     string
-										Number int
-									}
-								} `graphql:"associatedPullRequests(first: 100)"`
-							}
-						} `graphql:"history(since: $since, until: $until)"`
-					} `graphql:"... on Commit"`
-				}
-			} `graphql:"ref(qualifiedName: $ref)"`
-		} `graphql:"repository(owner: $repoOwner, name: $repoName)"`
-	}
+	owner, repo, branch := opts.Owner, opts.Repo, opts.Branch
 
 	prNodeIDs := map[string]bool{}
 
-	logger = logger.With("since", start, "until", end)
-
-	logger.Info("checking commits for associated PRs")
-	err := client.Query(ctx, &q, map[string]interface{}{
-		"repoOwner": githubv4.String(owner),
-		"repoName":  githubv4.String(repo),
-		"ref":       githubv4.String(fmt.Sprintf("refs/heads/%s", branch)),
-		"since":     githubv4.GitTimestamp{Time: start},
-		"until":     githubv4.GitTimestamp{Time: end},
-	})
+	commitPRs, err := associatedPullRequests(ctx, client, logger, opts.Cache, owner, repo, commits)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, hn := range q.Repository.Ref.Target.Commit.History.Nodes {
-		logger := logger.With("commit", hn.OID)
+	for _, c := range commits {
+		logger := logger.With("commit", c.OID)
 		logger.Debug("checking commit PRs")
 
-		if len(hn.AssociatedPullRequests.Nodes) == 100 {
-			// this is weird, should probably log it
+		prs := commitPRs[c.OID]
+		if len(prs) == 100 {
+			logger.Warn("commit has 100 associated PRs, some may be missing")
 		}
-		for _, prn := range hn.AssociatedPullRequests.Nodes {
+		for _, prn := range prs {
 			logger := logger.With("pr", prn.Number)
 
 			if prn.BaseRef.Name != branch ||
-				prn.BaseRef.Repository.Name != repo ||
-				prn.BaseRef.Repository.Owner.Login != owner {
-				logger.Debug("external PR, skipping")
-				continue
-			}
-
-			noChangelog := ""
-			for _, ln := range prn.Labels.Nodes {
-				for _, nrn := range noNoteLabels {
-					if ln.Name == nrn {
-						noChangelog = nrn
-						break
-					}
-				}
-			}
-			if noChangelog != "" {
-				logger.Debug(noChangelog + " label applied, skipping")
+				!strings.EqualFold(prn.BaseRef.Repository.Name, repo) ||
+				!strings.EqualFold(prn.BaseRef.Repository.Owner.Login, owner) {
+				logger.Debug("skipping PR", "reason", "external",
+					"base_owner", prn.BaseRef.Repository.Owner.Login,
+					"base_repo", prn.BaseRef.Repository.Name,
+					"base_ref", prn.BaseRef.Name,
+				)
 				continue
 			}
 
 			if prn.State != githubv4.PullRequestStateMerged {
-				logger.Debug("unmerged PR, skipping")
+				logger.Debug("skipping PR", "reason", "unmerged", "state", prn.State)
 				continue
 			}
 			// TODO: check base ref on PR to make sure its master?
@@ -173,6 +219,25 @@ func listPullRequestIDs(
 	return prIDs, nil
 }
 
+// noNoteLabel returns the first label of the PR that excludes it from the
+// changelog.
+func noNoteLabel(opts Options, pr pullRequest) (string, bool) {
+	return firstInSlice(opts.NoNoteLabels, pr.labels())
+}
+
+// dropNoNoteLabels returns the PRs without a no note label.
+func dropNoNoteLabels(logger hclog.Logger, opts Options, prs []pullRequest) []pullRequest {
+	var result []pullRequest
+	for _, pr := range prs {
+		if l, ok := noNoteLabel(opts, pr); ok {
+			logger.Debug("skipping PR", "pr", pr.Number, "reason", "no note label", "label", l)
+			continue
+		}
+		result = append(result, pr)
+	}
+	return result
+}
+
 func stringInSlice(haystack []string, needle string) bool {
 	for _, h := range haystack {
 		if h == needle {
@@ -186,59 +251,79 @@ func pullRequestsToReleaseNotes(
 	ctx context.Context,
 	client *githubv4.Client,
 	logger hclog.Logger,
+	opts Options,
 	prIDs []string,
 ) ([]ReleaseNote, error) {
-	var q struct {
-		Nodes []struct {
-			PullRequest struct {
-				MergedAt time.Time
-				ID       string
-				Number   int
-				Title    string
-				Body     string
-				URL      string
-				Author   struct {
-					Login string
-					URL   string
-				}
-				Labels struct {
-					Nodes []struct {
-						Name string
-					}
-				} `graphql:"labels(first: 100)"`
-			} `graphql:"... on PullRequest"`
-		} `graphql:"nodes(ids: $ids)"`
-	}
-
 	logger.Info("retrieving PRs to build release notes")
-	err := client.Query(ctx, &q, map[string]interface{}{
-		"ids": prIDs,
-	})
+	prs, err := fetchPullRequests(ctx, client, logger, opts.Cache, prIDs, opts.needsFiles())
 	if err != nil {
 		return nil, err
 	}
 
-	notes := make([]ReleaseNote, 0, len(q.Nodes))
-	for _, n := range q.Nodes {
-		logger := logger.With("pr", n.PullRequest.Number, "prid", n.PullRequest.ID)
+	return releaseNotesFromPullRequests(logger, opts, prs), nil
+}
+
+func releaseNotesFromPullRequests(
+	logger hclog.Logger,
+	opts Options,
+	prs []pullRequest,
+) []ReleaseNote {
+	patterns := authorPatterns(opts.AuthorPrefixes)
+
+	notes := make([]ReleaseNote, 0, len(prs))
+	for _, pr := range prs {
+		logger := logger.With("pr", pr.Number, "prid", pr.ID)
+
+		if !opts.Paths.Match(pr.files()) {
+			logger.Debug("skipping PR", "reason", "no matching paths", "files", len(pr.Files.Nodes))
+			continue
+		}
+
+		bot := isBot(pr.Author.Typename, pr.Author.Login, opts.BotLogins)
+		if bot && opts.ExcludeBots {
+			logger.Debug("skipping PR", "reason", "bot", "author", pr.Author.Login)
+			continue
+		}
 
 		logger.Info("building release note")
 
-		author, authorURL, found := authorFromPR(n.PullRequest.Body)
+		author, authorURL, found := authorFromPR(pr.Body, patterns)
 		if !found {
-			author = n.PullRequest.Author.Login
-			authorURL = n.PullRequest.Author.URL
+			author = pr.Author.Login
+			authorURL = pr.Author.URL
+
+			if bot {
+				if login, url, ok := firstHumanCommitAuthor(pr, opts.BotLogins); ok {
+					logger.Debug("crediting bot PR to commit author", "bot", author, "author", login)
+					author, authorURL = login, url
+				}
+			}
 		}
 
 		note := ReleaseNote{
-			PRDate:    n.PullRequest.MergedAt,
-			PRNumber:  n.PullRequest.Number,
-			PRURL:     strings.TrimSpace(n.PullRequest.URL),
+			PRDate:    pr.MergedAt,
+			PRNumber:  pr.Number,
+			PRURL:     strings.TrimSpace(pr.URL),
 			Author:    strings.TrimSpace(author),
 			AuthorURL: strings.TrimSpace(authorURL),
 		}
-
-		for _, ln := range n.PullRequest.Labels.Nodes {
+		note.Authors = authorsFromPR(pr, Author{
+			Login: note.Author,
+			URL:   note.AuthorURL,
+		}, patterns)
+		note.Issues = issuesFromPR(pr, opts.Owner, opts.Repo)
+		note.Milestone = pr.Milestone.Title
+		note.MergeCommitOID = pr.MergeCommit.OID
+		note.MergeCommitAbbreviatedOID = pr.MergeCommit.AbbreviatedOID
+		note.Reviewers = reviewersFromPR(pr)
+		note.Additions = pr.Additions
+		note.Deletions = pr.Deletions
+		note.ChangedFiles = pr.ChangedFiles
+		note.OriginalPRNumbers = backportReferences(pr.Title, pr.Body)
+
+		labels := make([]string, 0, len(pr.Labels.Nodes))
+		for _, ln := range pr.Labels.Nodes {
+			labels = append(labels, ln.Name)
 			switch {
 			case stringInSlice(labelsBug, ln.Name):
 				note.Bug = true
@@ -249,83 +334,141 @@ func pullRequestsToReleaseNotes(
 			}
 		}
 
-		for _, entry := range ReleaseNoteBlocks(n.PullRequest.Title, n.PullRequest.Body) {
+		labelType := typeFromLabels(opts.TypeLabels, labels)
+
+		for i, entry := range ReleaseNoteBlocks(pr.Title, pr.Body) {
 			n := note
+			n.BlockIndex = i
 			n.Text = entry.Text
 			n.Type = entry.Type
+			n.Metadata = entry.Metadata
+			if n.Type == "" {
+				n.Type = labelType
+			}
 			notes = append(notes, n)
 		}
 	}
 
-	return notes, nil
+	return notes
 }
 
 var textInBodyREs = []*regexp.Regexp{
-	regexp.MustCompile("(?m)^```release-notes?\n(?P<note>.*)\n?```"),
-	regexp.MustCompile("(?m)^```releasenotes?\n(?P<note>.*)\n?```"),
-	regexp.MustCompile("(?m)^```release-notes?:(?P<type>.*)\n?(?P<note>.*)\n?```"),
-	regexp.MustCompile("(?m)^```releasenotes?:(?P<type>.*)\n?(?P<note>.*)\n?```"),
+	regexp.MustCompile("(?m)^```release-notes?(?:[ \t]+(?P<attrs>[^\n]*))?\n(?P<note>.*)\n?```"),
+	regexp.MustCompile("(?m)^```releasenotes?(?:[ \t]+(?P<attrs>[^\n]*))?\n(?P<note>.*)\n?```"),
+	regexp.MustCompile("(?m)^```release-notes?:(?P<info>.*)\n?(?P<note>.*)\n?```"),
+	regexp.MustCompile("(?m)^```releasenotes?:(?P<info>.*)\n?(?P<note>.*)\n?```"),
+}
+
+// fenceAttributeRE matches a key=value attribute of a fence info string, the
+// value may be double quoted to include spaces.
+var fenceAttributeRE = regexp.MustCompile(`^([A-Za-z0-9_.-]+)=("[^"]*"|\S*)`)
+
+// parseFenceInfo splits the info string of a release note block after the
+// "release-note" or "release-note:" prefix into the type and key=value
+// metadata. The type is everything before the first attribute, and anything
+// after it that is not an attribute is returned as invalid.
+func parseFenceInfo(info string) (string, map[string]string, []string) {
+	var typ string
+	var metadata map[string]string
+	var invalid []string
+
+	rest := strings.TrimSpace(info)
+	for rest != "" {
+		match := fenceAttributeRE.FindStringSubmatch(rest)
+		if match == nil {
+			i := strings.IndexAny(rest, " \t")
+			if i < 0 {
+				i = len(rest)
+			}
+			if metadata == nil {
+				typ += " " + rest[:i]
+			} else {
+				invalid = append(invalid, rest[:i])
+			}
+			rest = strings.TrimSpace(rest[i:])
+			continue
+		}
+
+		if metadata == nil {
+			metadata = map[string]string{}
+		}
+		metadata[match[1]] = strings.Trim(match[2], `"`)
+		rest = strings.TrimSpace(rest[len(match[0]):])
+	}
+
+	return strings.TrimSpace(typ), metadata, invalid
 }
 
 // ReleaseNoteBlocks accepts the PR title and body contents, and parses them
-// into one or more `ReleaseNoteEntry`s. It first attempts to find explicit
-// releasenote code blocks within the body, and failing that, falls back on
-// using the PR title as long as it is not empty.
+// into one or more `ReleaseNoteEntry`s in the order they appear in the body.
+// It first attempts to find explicit releasenote code blocks within the body,
+// and failing that, falls back on using the PR title as long as it is not
+// empty.
 func ReleaseNoteBlocks(title, body string) []ReleaseNoteEntry {
-	var res []ReleaseNoteEntry
+	type block struct {
+		offset int
+		entry  ReleaseNoteEntry
+	}
+	var blocks []block
 	for _, re := range textInBodyREs {
-		matches := re.FindAllStringSubmatch(body, -1)
+		matches := re.FindAllStringSubmatchIndex(body, -1)
 		if len(matches) == 0 {
 			continue
 		}
 
 		for _, match := range matches {
 			note := ""
-			typ := ""
+			info := ""
+			typed := false
 			for i, name := range re.SubexpNames() {
+				if match[2*i] < 0 {
+					continue
+				}
 				switch name {
 				case "note":
-					note = match[i]
-				case "type":
-					typ = match[i]
-				}
-				if note != "" && typ != "" {
-					break
+					note = body[match[2*i]:match[2*i+1]]
+				case "info", "attrs":
+					info = body[match[2*i]:match[2*i+1]]
+					typed = name == "info"
 				}
 			}
 
 			note = stripMarkdownBullet(note)
 
 			note = strings.TrimSpace(note)
-			typ = strings.TrimSpace(typ)
+			typ, metadata, _ := parseFenceInfo(info)
+			if typ != "" && !typed {
+				// only attributes may follow an untyped fence
+				continue
+			}
 
 			if note == "" && typ == "" {
 				continue
 			}
 
-			res = append(res, ReleaseNoteEntry{
-				Type: typ,
-				Text: note,
+			blocks = append(blocks, block{
+				offset: match[0],
+				entry: ReleaseNoteEntry{
+					Type:     typ,
+					Text:     note,
+					Metadata: metadata,
+				},
 			})
 		}
 	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].offset < blocks[j].offset
+	})
+
+	var res []ReleaseNoteEntry
+	for _, b := range blocks {
+		res = append(res, b.entry)
+	}
 	if len(res) < 1 && title != "" {
 		res = append(res, ReleaseNoteEntry{
 			Text: title,
 		})
 	}
-	sort.Slice(res, func(i, j int) bool {
-		if res[i].Type < res[j].Type {
-			return true
-		} else if res[j].Type < res[i].Type {
-			return false
-		} else if res[i].Text < res[j].Text {
-			return true
-		} else if res[j].Text < res[i].Text {
-			return false
-		}
-		return false
-	})
 	return res
 }
 
@@ -338,10 +481,45 @@ var authorInBodyREs = []*regexp.Regexp{
 	// /cc syntax is too ambiguous probably
 	// regexp.MustCompile("(?m)^/[Cc][Cc] *@(?P<login>.+)"),
 	regexp.MustCompile("(?m)^(\\*\\*)?[Oo]riginal [Aa]uthor:(\\*\\*)? *@(?P<login>.+)"),
+	regexp.MustCompile("(?mi)^(\\*\\*)?contributed-by:(\\*\\*)? *@(?P<login>[A-Za-z0-9-]+)"),
+	regexp.MustCompile("(?m)^/author +@(?P<login>[A-Za-z0-9-]+)"),
+}
+
+// authorPatterns returns the built-in author override patterns followed by
+// patterns for lines starting with each of the additional prefixes, for
+// example "Reported-by:" matches "Reported-by: @login".
+func authorPatterns(prefixes []string) []*regexp.Regexp {
+	patterns := append([]*regexp.Regexp{}, authorInBodyREs...)
+	for _, prefix := range prefixes {
+		patterns = append(patterns, regexp.MustCompile(
+			"(?mi)^(\\*\\*)?"+regexp.QuoteMeta(prefix)+"(\\*\\*)? *@(?P<login>[A-Za-z0-9-]+)",
+		))
+	}
+	return patterns
+}
+
+// overrideAuthorsFromPR returns the logins from all override author lines in
+// the body.
+func overrideAuthorsFromPR(body string, patterns []*regexp.Regexp) []string {
+	var logins []string
+	for _, re := range patterns {
+		for _, match := range re.FindAllStringSubmatch(body, -1) {
+			for i, name := range re.SubexpNames() {
+				if name != "login" {
+					continue
+				}
+				login := strings.TrimSpace(strings.TrimLeft(match[i], "@"))
+				if login != "" {
+					logins = append(logins, login)
+				}
+			}
+		}
+	}
+	return logins
 }
 
-func authorFromPR(body string) (string, string, bool) {
-	for _, re := range authorInBodyREs {
+func authorFromPR(body string, patterns []*regexp.Regexp) (string, string, bool) {
+	for _, re := range patterns {
 		match := re.FindStringSubmatch(body)
 		if len(match) == 0 {
 			continue
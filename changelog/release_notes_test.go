@@ -2,9 +2,9 @@ package changelog
 
 import (
 	"fmt"
-	"sort"
 	"testing"
 
+	hclog "github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,6 +40,15 @@ func TestTextFromPR(t *testing.T) {
 		// text in body, type in body, multiple blocks
 		{[]ReleaseNoteEntry{{Type: "bug", Text: "foo"}, {Type: "enhancement", Text: "bar"}},
 			"", "\n```releasenote:bug\nfoo\n```\n\n```release-note:enhancement\nbar\n```\n"},
+		{[]ReleaseNoteEntry{{Type: "enhancement", Text: "bar"}, {Type: "bug", Text: "foo"}, {Text: "baz"}},
+			"", "\n```release-note:enhancement\nbar\n```\n\n```releasenote:bug\nfoo\n```\n\n```release-note\nbaz\n```\n"},
+
+		// metadata in the fence info
+		{[]ReleaseNoteEntry{{Type: "bug", Text: "foo", Metadata: map[string]string{"scope": "ec2", "docs": "/guides/x"}}},
+			"", "```release-note:bug scope=ec2 docs=/guides/x\nfoo\n```"},
+		{[]ReleaseNoteEntry{{Text: "foo", Metadata: map[string]string{"upgrade": "true"}}},
+			"", "```release-note upgrade=true\nfoo\n```"},
+		{[]ReleaseNoteEntry{{Text: "bar"}}, "bar", "```release-note foo\nfoo\n```"},
 
 		// text in body, no note
 		{[]ReleaseNoteEntry{{Type: "none", Text: ""}}, "", "```release-note:none\n\n```"},
@@ -52,21 +61,32 @@ func TestTextFromPR(t *testing.T) {
 		{nil, "", "```release-note\n```"},
 	} {
 		t.Run(fmt.Sprintf("%d %s", i, c.expected), func(t *testing.T) {
-			res := c.expected
-			sort.Slice(res, func(i, j int) bool {
-				if res[i].Type < res[j].Type {
-					return true
-				} else if res[j].Type < res[i].Type {
-					return false
-				} else if res[i].Text < res[j].Text {
-					return true
-				} else if res[j].Text < res[i].Text {
-					return false
-				}
-				return false
-			})
 			actual := ReleaseNoteBlocks(c.title, c.body)
-			assert.Equal(t, res, actual)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestParseFenceInfo(t *testing.T) {
+	for i, c := range []struct {
+		typ      string
+		metadata map[string]string
+		invalid  []string
+		info     string
+	}{
+		{"", nil, nil, ""},
+		{"bug", nil, nil, "bug"},
+		{"new resource", nil, nil, " new resource "},
+		{"bug", map[string]string{"scope": "ec2"}, nil, "bug scope=ec2"},
+		{"", map[string]string{"scope": "ec2", "empty": ""}, nil, "scope=ec2 empty="},
+		{"bug", map[string]string{"docs": "/guides/a b"}, nil, `bug docs="/guides/a b"`},
+		{"bug", map[string]string{"scope": "ec2"}, []string{"upgrade"}, "bug scope=ec2 upgrade"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.info), func(t *testing.T) {
+			typ, metadata, invalid := parseFenceInfo(c.info)
+			assert.Equal(t, c.typ, typ)
+			assert.Equal(t, c.metadata, metadata)
+			assert.Equal(t, c.invalid, invalid)
 		})
 	}
 }
@@ -87,9 +107,15 @@ func TestAuthorFromPR(t *testing.T) {
 		{"foo", "\n**Original Author:** @foo\n"},
 
 		{"", "\n **Original Author:** @foo\n"},
+
+		{"foo", "Contributed-by: @foo"},
+		{"foo", "**Contributed-By:** @foo and others"},
+		{"foo", "/author @foo"},
+		{"foo", "Reported-by: @foo"},
+		{"", "/author foo"},
 	} {
 		t.Run(fmt.Sprintf("%d %s", i, c.expected), func(t *testing.T) {
-			actual, actualURL, ok := authorFromPR(c.body)
+			actual, actualURL, ok := authorFromPR(c.body, authorPatterns([]string{"Reported-by:"}))
 			assert.Equal(t, c.expected != "", ok)
 			if ok {
 				assert.Equal(t, c.expected, actual)
@@ -99,3 +125,71 @@ func TestAuthorFromPR(t *testing.T) {
 		})
 	}
 }
+
+func TestTypeFromLabels(t *testing.T) {
+	rules := []TypeLabel{
+		{Label: "breaking-change", Type: "breaking-change"},
+		{Label: "kind/feature", Type: "feature"},
+		{Label: "type/*"},
+		{Label: "service/*", Type: "improvement"},
+	}
+
+	for i, c := range []struct {
+		expected string
+		labels   []string
+	}{
+		// zero case
+		{"", nil},
+		{"", []string{"foo"}},
+
+		{"feature", []string{"kind/feature"}},
+		{"enhancement", []string{"type/enhancement"}},
+		{"improvement", []string{"service/ec2"}},
+
+		// earlier rules take precedence regardless of label order
+		{"feature", []string{"type/enhancement", "kind/feature"}},
+		{"breaking-change", []string{"kind/feature", "breaking-change"}},
+
+		// empty suffix does not match
+		{"", []string{"type/"}},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.expected), func(t *testing.T) {
+			actual := typeFromLabels(rules, c.labels)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestReleaseNotesFromPullRequests_bots(t *testing.T) {
+	pr := func(number int, login, body string) pullRequest {
+		var pr pullRequest
+		pr.Number = number
+		pr.Title = fmt.Sprintf("pr %d", number)
+		pr.Body = body
+		pr.Author.Typename = "User"
+		pr.Author.Login = login
+		pr.Commits.Nodes = make([]pullRequestCommit, 1)
+		pr.Commits.Nodes[0].Commit.Author.User = &user{Login: "foo", URL: "https://github.com/foo"}
+		return pr
+	}
+	prs := []pullRequest{
+		pr(1, "foo", ""),
+		pr(2, "dependabot[bot]", ""),
+		pr(3, "sync-bot", "Original Author: @bar"),
+	}
+
+	authors := func(notes []ReleaseNote) []string {
+		var authors []string
+		for _, n := range notes {
+			authors = append(authors, fmt.Sprintf("%d %s", n.PRNumber, n.Author))
+		}
+		return authors
+	}
+
+	logger := hclog.NewNullLogger()
+	opts := Options{BotLogins: []string{"sync-bot"}}
+	assert.Equal(t, []string{"1 foo", "2 foo", "3 bar"}, authors(releaseNotesFromPullRequests(logger, opts, prs)))
+
+	opts.ExcludeBots = true
+	assert.Equal(t, []string{"1 foo"}, authors(releaseNotesFromPullRequests(logger, opts, prs)))
+}
@@ -0,0 +1,189 @@
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// DefaultAPIURL is the base URL of the GitHub REST API.
+const DefaultAPIURL = "https://api.github.com/"
+
+// maxReleasePages limits how many pages of releases are searched for an
+// existing release, drafts can only be found by listing releases.
+const maxReleasePages = 10
+
+// RESTClient makes requests to the GitHub REST API, which is needed for the
+// parts of the API that are not available via GraphQL, like releases.
+type RESTClient struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+// NewRESTClient returns a client for the REST API at baseURL, DefaultAPIURL
+// if empty. The HTTP client is expected to authenticate requests.
+func NewRESTClient(httpClient *http.Client, baseURL string) (*RESTClient, error) {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API URL %q: %w", baseURL, err)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &RESTClient{
+		baseURL:    u,
+		httpClient: httpClient,
+	}, nil
+}
+
+// APIError is an unsuccessful response from the REST API.
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("GitHub API returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("GitHub API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends a request with in encoded as JSON, if not nil, and decodes the
+// response into out, if not nil.
+func (c *RESTClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	u, err := c.baseURL.Parse(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if in != nil {
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(content, apiErr)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(content, out)
+}
+
+// Release is a GitHub Release.
+type Release struct {
+	ID              int64  `json:"id,omitempty"`
+	TagName         string `json:"tag_name"`
+	TargetCommitish string `json:"target_commitish,omitempty"`
+	Name            string `json:"name"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+	HTMLURL         string `json:"html_url,omitempty"`
+}
+
+// findRelease returns the release for the tag, including draft releases.
+func findRelease(ctx context.Context, client *RESTClient, owner, repo, tag string) (*Release, error) {
+	for page := 1; page <= maxReleasePages; page++ {
+		var releases []Release
+		path := fmt.Sprintf("repos/%s/%s/releases?per_page=100&page=%d", owner, repo, page)
+		if err := client.do(ctx, http.MethodGet, path, nil, &releases); err != nil {
+			return nil, err
+		}
+		for _, r := range releases {
+			if r.TagName == tag {
+				return &r, nil
+			}
+		}
+		if len(releases) < 100 {
+			break
+		}
+	}
+	return nil, nil
+}
+
+// PublishRelease creates the release for its tag, or updates the existing
+// release if it differs, so publishing the same release again does nothing.
+// The published release is returned.
+func PublishRelease(
+	ctx context.Context,
+	client *RESTClient,
+	logger hclog.Logger,
+	owner, repo string,
+	release Release,
+) (Release, error) {
+	logger = logger.With("tag", release.TagName)
+
+	existing, err := findRelease(ctx, client, owner, repo, release.TagName)
+	if err != nil {
+		return Release{}, fmt.Errorf("error finding release: %w", err)
+	}
+
+	if existing == nil {
+		var created Release
+		err := client.do(ctx, http.MethodPost, fmt.Sprintf("repos/%s/%s/releases", owner, repo), release, &created)
+		if err != nil {
+			return Release{}, fmt.Errorf("error creating release: %w", err)
+		}
+		logger.Info("created release", "url", created.HTMLURL, "draft", created.Draft)
+		return created, nil
+	}
+
+	if existing.Name == release.Name &&
+		existing.Body == release.Body &&
+		existing.Draft == release.Draft &&
+		existing.Prerelease == release.Prerelease &&
+		(release.TargetCommitish == "" || existing.TargetCommitish == release.TargetCommitish) {
+		logger.Info("release is up to date", "url", existing.HTMLURL)
+		return *existing, nil
+	}
+
+	var updated Release
+	err = client.do(ctx, http.MethodPatch, fmt.Sprintf("repos/%s/%s/releases/%d", owner, repo, existing.ID), release, &updated)
+	if err != nil {
+		return Release{}, fmt.Errorf("error updating release: %w", err)
+	}
+	logger.Info("updated release", "url", updated.HTMLURL, "draft", updated.Draft)
+	return updated, nil
+}
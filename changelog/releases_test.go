@@ -0,0 +1,129 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeReleasesAPI is an in-memory implementation of the release endpoints of
+// the GitHub REST API.
+type fakeReleasesAPI struct {
+	mu       sync.Mutex
+	releases []Release
+	writes   int
+}
+
+func (f *fakeReleasesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const prefix = "/api/v3/repos/foo/bar/releases"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	var in Release
+	if r.Method == http.MethodPost || r.Method == http.MethodPatch {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.writes++
+	}
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page > 1 {
+			json.NewEncoder(w).Encode([]Release{})
+			return
+		}
+		json.NewEncoder(w).Encode(f.releases)
+	case r.Method == http.MethodPost && id == "":
+		in.ID = int64(len(f.releases) + 1)
+		in.HTMLURL = fmt.Sprintf("https://github.com/foo/bar/releases/tag/%s", in.TagName)
+		f.releases = append(f.releases, in)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(in)
+	case r.Method == http.MethodPatch:
+		for i, existing := range f.releases {
+			if strconv.FormatInt(existing.ID, 10) != id {
+				continue
+			}
+			in.ID = existing.ID
+			in.HTMLURL = existing.HTMLURL
+			f.releases[i] = in
+			json.NewEncoder(w).Encode(in)
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"message": "method not allowed"})
+	}
+}
+
+func TestPublishRelease(t *testing.T) {
+	api := &fakeReleasesAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client, err := NewRESTClient(server.Client(), server.URL+"/api/v3")
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+
+	release := Release{
+		TagName: "v1.0.0",
+		Name:    "v1.0.0",
+		Body:    "first",
+		Draft:   true,
+	}
+
+	created, err := PublishRelease(ctx, client, logger, "foo", "bar", release)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created.ID)
+	assert.Equal(t, 1, api.writes)
+
+	// publishing the same release again is a no-op
+	_, err = PublishRelease(ctx, client, logger, "foo", "bar", release)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, api.writes)
+
+	release.Body = "second"
+	release.Draft = false
+	updated, err := PublishRelease(ctx, client, logger, "foo", "bar", release)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), updated.ID)
+	assert.Equal(t, "second", updated.Body)
+	assert.False(t, updated.Draft)
+	assert.Equal(t, 2, api.writes)
+	assert.Len(t, api.releases, 1)
+}
+
+func TestPublishRelease_apiError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewRESTClient(server.Client(), server.URL)
+	assert.NoError(t, err)
+
+	_, err = PublishRelease(context.Background(), client, hclog.NewNullLogger(), "foo", "bar", Release{TagName: "v1.0.0"})
+	assert.EqualError(t, err, "error finding release: GitHub API returned status 403: Resource not accessible by integration")
+}
@@ -0,0 +1,209 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+var (
+	// revertsPRRE matches the body of the PRs opened by GitHub's revert
+	// button, like "Reverts owner/repo#123".
+	revertsPRRE = regexp.MustCompile(`(?m)^Reverts ([A-Za-z0-9-]+)/([A-Za-z0-9_.-]+)#([0-9]+)\b`)
+
+	// revertsCommitRE matches the message of commits created by git revert.
+	revertsCommitRE = regexp.MustCompile(`(?m)^This reverts commit ([0-9a-f]{7,40})\b`)
+)
+
+// revertedCommits returns the commits named by git revert in the message.
+func revertedCommits(message string) []string {
+	var shas []string
+	for _, match := range revertsCommitRE.FindAllStringSubmatch(message, -1) {
+		shas = append(shas, match[1])
+	}
+	return shas
+}
+
+// revertedPullRequest returns the number of the PR in owner/repo reverted
+// according to the body, or 0.
+func revertedPullRequest(body, owner, repo string) int {
+	for _, match := range revertsPRRE.FindAllStringSubmatch(body, -1) {
+		if !strings.EqualFold(match[1], owner) || !strings.EqualFold(match[2], repo) {
+			continue
+		}
+		if n, err := strconv.Atoi(match[3]); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// resolveReverts returns the number of the PR reverted by each revert PR,
+// keyed by the number of the revert. The reverted PR is found by the body of
+// the revert or the commits it reverts, first among prs and then by looking
+// up the commits. Reverts of commits that are not part of a PR merged into
+// the branch map to 0.
+func resolveReverts(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	prs []pullRequest,
+) (map[int]int, error) {
+	byCommit := map[string]int{}
+	for _, pr := range prs {
+		if pr.MergeCommit.OID != "" {
+			byCommit[pr.MergeCommit.OID] = pr.Number
+		}
+		for _, n := range pr.Commits.Nodes {
+			if n.Commit.OID != "" {
+				byCommit[n.Commit.OID] = pr.Number
+			}
+		}
+	}
+	lookup := func(sha string) (int, bool) {
+		for oid, number := range byCommit {
+			if strings.HasPrefix(oid, sha) {
+				return number, true
+			}
+		}
+		return 0, false
+	}
+
+	reverts := map[int]int{}
+	for _, pr := range prs {
+		if number := revertedPullRequest(pr.Body, opts.Owner, opts.Repo); number != 0 {
+			reverts[pr.Number] = number
+			continue
+		}
+
+		var shas []string
+		for _, n := range pr.Commits.Nodes {
+			shas = append(shas, revertedCommits(n.Commit.Message)...)
+		}
+		if len(shas) == 0 {
+			continue
+		}
+
+		reverts[pr.Number] = 0
+		for _, sha := range shas {
+			if number, ok := lookup(sha); ok && number != pr.Number {
+				reverts[pr.Number] = number
+				break
+			}
+			number, err := branchPullRequest(ctx, client, opts, sha)
+			if err != nil {
+				return nil, fmt.Errorf("error looking up reverted commit %s: %w", sha, err)
+			}
+			if number != 0 && number != pr.Number {
+				reverts[pr.Number] = number
+				break
+			}
+		}
+		if reverts[pr.Number] == 0 {
+			logger.Debug("unable to find reverted PR", "pr", pr.Number, "commits", shas)
+		}
+	}
+
+	return reverts, nil
+}
+
+// branchPullRequest returns the number of the merged PR into the branch that
+// contains the commit, or 0.
+func branchPullRequest(ctx context.Context, client *githubv4.Client, opts Options, sha string) (int, error) {
+	prs, err := commitAssociatedPullRequests(ctx, client, opts.Owner, opts.Repo, sha)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, pr := range prs {
+		if pr.State == githubv4.PullRequestStateMerged &&
+			pr.BaseRef.Name == opts.Branch &&
+			strings.EqualFold(pr.BaseRef.Repository.Name, opts.Repo) &&
+			strings.EqualFold(pr.BaseRef.Repository.Owner.Login, opts.Owner) {
+			return pr.Number, nil
+		}
+	}
+	return 0, nil
+}
+
+// applyReverts marks the notes of revert PRs.
+func applyReverts(notes []ReleaseNote, owner, repo string, reverts map[int]int) {
+	for i, n := range notes {
+		number, ok := reverts[n.PRNumber]
+		if !ok {
+			continue
+		}
+		notes[i].Revert = true
+		if number != 0 {
+			notes[i].RevertedPRNumber = number
+			notes[i].RevertedPRURL = fmt.Sprintf("https://github.com/%s/%s/pull/%d", owner, repo, number)
+		}
+	}
+}
+
+// dropRevertedPairs drops the notes of PRs reverted by a later PR in the
+// notes along with the notes of the revert, as together they make no change.
+// Reverting a revert restores the notes of the PR it reverted. Reverts of PRs
+// not in the notes, like those in a previous release, are kept.
+func dropRevertedPairs(logger hclog.Logger, notes []ReleaseNote) []ReleaseNote {
+	byNumber := map[int]ReleaseNote{}
+	var reverts []ReleaseNote
+	for _, n := range notes {
+		if _, ok := byNumber[n.PRNumber]; ok {
+			continue
+		}
+		byNumber[n.PRNumber] = n
+		if n.RevertedPRNumber != 0 {
+			reverts = append(reverts, n)
+		}
+	}
+	if len(reverts) == 0 {
+		return notes
+	}
+
+	// pair reverts in the order they were merged, so a revert of a revert
+	// sees the first pair
+	sort.SliceStable(reverts, func(i, j int) bool {
+		return reverts[i].PRDate.Before(reverts[j].PRDate)
+	})
+
+	dropped := map[int]bool{}
+	cancelled := map[int]int{}
+	for _, r := range reverts {
+		original := r.RevertedPRNumber
+		if _, ok := byNumber[original]; !ok {
+			continue
+		}
+
+		dropped[r.PRNumber] = true
+		if !dropped[original] {
+			logger.Info("dropping reverted PR", "pr", original, "revert", r.PRNumber)
+			dropped[original] = true
+			cancelled[r.PRNumber] = original
+			continue
+		}
+
+		// reverting a revert that was already paired relands its original
+		if relanded, ok := cancelled[original]; ok {
+			logger.Info("restoring relanded PR", "pr", relanded, "revert", r.PRNumber)
+			dropped[relanded] = false
+			delete(cancelled, original)
+		}
+	}
+
+	var kept []ReleaseNote
+	for _, n := range notes {
+		if dropped[n.PRNumber] {
+			continue
+		}
+		kept = append(kept, n)
+	}
+	return kept
+}
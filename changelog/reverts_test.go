@@ -0,0 +1,119 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevertedCommits(t *testing.T) {
+	for i, c := range []struct {
+		expected []string
+		message  string
+	}{
+		{nil, ""},
+		{nil, "Fix foo"},
+		{[]string{"0123456789abcdef0123456789abcdef01234567"}, "Revert \"Fix foo\"\n\nThis reverts commit 0123456789abcdef0123456789abcdef01234567."},
+		{[]string{"abc1234", "def5678"}, "Revert fixes\n\nThis reverts commit abc1234.\nThis reverts commit def5678, reversing\nchanges made to 1234567."},
+		{nil, "Mention: This reverts commit abc1234."},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.message), func(t *testing.T) {
+			assert.Equal(t, c.expected, revertedCommits(c.message))
+		})
+	}
+}
+
+func TestRevertedPullRequest(t *testing.T) {
+	for i, c := range []struct {
+		expected int
+		body     string
+	}{
+		{0, ""},
+		{0, "Fixes #12"},
+		{12, "Reverts foo/bar#12"},
+		{12, "Reverts Foo/Bar#12\n\nBroke the build."},
+		{0, "Reverts foo/baz#12"},
+		{0, "This PR reverts foo/bar#12"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.body), func(t *testing.T) {
+			assert.Equal(t, c.expected, revertedPullRequest(c.body, "foo", "bar"))
+		})
+	}
+}
+
+func TestResolveReverts(t *testing.T) {
+	pr := func(number int, body, mergeCommit string, messages ...string) pullRequest {
+		pr := pullRequest{Number: number, Body: body}
+		pr.MergeCommit.OID = mergeCommit
+		for _, m := range messages {
+			var c pullRequestCommit
+			c.Commit.Message = m
+			pr.Commits.Nodes = append(pr.Commits.Nodes, c)
+		}
+		return pr
+	}
+
+	reverts, err := resolveReverts(context.Background(), nil, hclog.NewNullLogger(), Options{Owner: "foo", Repo: "bar"}, []pullRequest{
+		pr(1, "", "aaaaaaaaaa"),
+		pr(2, "Reverts foo/bar#1", "bbbbbbbbbb", "Revert \"Fix foo\"\n\nThis reverts commit aaaaaaaaaa."),
+		pr(3, "", "cccccccccc", "Revert \"Revert \"Fix foo\"\"\n\nThis reverts commit bbbbbbb."),
+		pr(4, "Reverts foo/bar#100", "dddddddddd"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{2: 1, 3: 2, 4: 100}, reverts)
+}
+
+func TestDropRevertedPairs(t *testing.T) {
+	for i, c := range []struct {
+		expected []int
+		notes    []ReleaseNote
+	}{
+		{
+			[]int{2},
+			[]ReleaseNote{{PRNumber: 2, PRDate: day(2)}},
+		},
+		{
+			nil,
+			[]ReleaseNote{
+				{PRNumber: 2, PRDate: day(2), RevertedPRNumber: 1},
+				{PRNumber: 1, PRDate: day(1)},
+				{PRNumber: 1, PRDate: day(1)},
+			},
+		},
+		{
+			// reverts a PR in a previous release
+			[]int{3, 2},
+			[]ReleaseNote{
+				{PRNumber: 3, PRDate: day(3)},
+				{PRNumber: 2, PRDate: day(2), RevertedPRNumber: 1},
+			},
+		},
+		{
+			// revert of a revert relands the original
+			[]int{1},
+			[]ReleaseNote{
+				{PRNumber: 3, PRDate: day(3), RevertedPRNumber: 2},
+				{PRNumber: 2, PRDate: day(2), RevertedPRNumber: 1},
+				{PRNumber: 1, PRDate: day(1)},
+			},
+		},
+		{
+			// reverted again after the reland
+			nil,
+			[]ReleaseNote{
+				{PRNumber: 4, PRDate: day(4), RevertedPRNumber: 1},
+				{PRNumber: 3, PRDate: day(3), RevertedPRNumber: 2},
+				{PRNumber: 2, PRDate: day(2), RevertedPRNumber: 1},
+				{PRNumber: 1, PRDate: day(1)},
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			actual := dropRevertedPairs(hclog.NewNullLogger(), c.notes)
+			assert.Equal(t, c.expected, noteNumbers(actual))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bump is the part of a semantic version incremented for a release.
+type Bump int
+
+const (
+	BumpPatch Bump = iota
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpMajor:
+		return "major"
+	case BumpMinor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// ParseBump parses "major", "minor" or "patch".
+func ParseBump(s string) (Bump, error) {
+	switch strings.ToLower(s) {
+	case "major":
+		return BumpMajor, nil
+	case "minor":
+		return BumpMinor, nil
+	case "patch":
+		return BumpPatch, nil
+	}
+	return BumpPatch, fmt.Errorf("invalid version bump %q, expected major, minor or patch", s)
+}
+
+// BumpRule maps a release note type to the version bump it requires.
+type BumpRule struct {
+	Type string
+	Bump Bump
+}
+
+// DefaultBumpRules are used by SuggestBump after any configured rules.
+// Breaking changes require a major release, and new functionality, including
+// enhancements of existing features, a minor release. Notes of other types,
+// like bug fixes, require a patch release.
+var DefaultBumpRules = []BumpRule{
+	{Type: "breaking-change", Bump: BumpMajor},
+	{Type: "breaking", Bump: BumpMajor},
+	{Type: "feature", Bump: BumpMinor},
+	{Type: "enhancement", Bump: BumpMinor},
+	{Type: "new-resource", Bump: BumpMinor},
+	{Type: "new-data-source", Bump: BumpMinor},
+}
+
+// SuggestBump returns the largest version bump required by the notes. Notes
+// marked as breaking changes always require a major bump, otherwise the
+// first rule matching the note type applies, falling back to
+// DefaultBumpRules and then a patch bump. Notes of the "none" type are
+// ignored.
+func SuggestBump(notes []ReleaseNote, rules []BumpRule) Bump {
+	rules = append(append([]BumpRule{}, rules...), DefaultBumpRules...)
+
+	bump := BumpPatch
+	for _, note := range notes {
+		if note.Type == typeNone {
+			continue
+		}
+		if note.BreakingChange {
+			return BumpMajor
+		}
+		for _, r := range rules {
+			if r.Type != note.Type {
+				continue
+			}
+			if r.Bump > bump {
+				bump = r.Bump
+			}
+			break
+		}
+	}
+	return bump
+}
+
+var versionRE = regexp.MustCompile(`^(v?)([0-9]+)\.([0-9]+)\.([0-9]+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// version is a parsed semantic version, build metadata is discarded.
+type version struct {
+	prefix              string
+	major, minor, patch int
+	prerelease          string
+}
+
+func parseVersion(s string) (version, error) {
+	match := versionRE.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return version{}, fmt.Errorf("%q is not a semantic version", s)
+	}
+
+	v := version{
+		prefix:     match[1],
+		prerelease: match[5],
+	}
+	var err error
+	if v.major, err = strconv.Atoi(match[2]); err != nil {
+		return version{}, err
+	}
+	if v.minor, err = strconv.Atoi(match[3]); err != nil {
+		return version{}, err
+	}
+	if v.patch, err = strconv.Atoi(match[4]); err != nil {
+		return version{}, err
+	}
+	return v, nil
+}
+
+// ValidVersion returns true if s is a semantic version, optionally prefixed
+// with "v".
+func ValidVersion(s string) bool {
+	_, err := parseVersion(s)
+	return err == nil
+}
+
+// IsPrerelease returns true if s is a semantic version with a pre-release
+// part, like "v1.2.0-beta.1".
+func IsPrerelease(s string) bool {
+	v, err := parseVersion(s)
+	return err == nil && v.prerelease != ""
+}
+
+func (v version) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.prefix, v.major, v.minor, v.patch)
+	if v.prerelease != "" {
+		s += "-" + v.prerelease
+	}
+	return s
+}
+
+// NextVersion returns the version following current for the bump, keeping
+// any "v" prefix. If prerelease is set, the result is a pre-release with
+// that identifier, like "1.3.0-beta.1".
+//
+// While the major version is 0 anything may change, so a major bump only
+// increments the minor version, for example "0.3.1" is followed by "0.4.0"
+// rather than "1.0.0".
+//
+// A pre-release current version is already ahead of the previous release,
+// so it is only bumped further if it does not cover the bump, for example
+// "1.3.0-beta.2" is followed by "1.3.0" (or "1.3.0-beta.3") for a minor bump
+// but "2.0.0" for a major one.
+func NextVersion(current string, bump Bump, prerelease string) (string, error) {
+	v, err := parseVersion(current)
+	if err != nil {
+		return "", err
+	}
+
+	if v.major == 0 && bump == BumpMajor {
+		bump = BumpMinor
+	}
+
+	previous := v
+	if v.prerelease == "" || !coversBump(v, bump) {
+		switch bump {
+		case BumpMajor:
+			v.major, v.minor, v.patch = v.major+1, 0, 0
+		case BumpMinor:
+			v.minor, v.patch = v.minor+1, 0
+		default:
+			v.patch++
+		}
+	}
+	v.prerelease = ""
+
+	if prerelease != "" {
+		n := 1
+		if previous.major == v.major && previous.minor == v.minor && previous.patch == v.patch {
+			if i, ok := prereleaseNumber(previous.prerelease, prerelease); ok {
+				n = i + 1
+			}
+		}
+		v.prerelease = fmt.Sprintf("%s.%d", prerelease, n)
+	}
+
+	return v.String(), nil
+}
+
+// coversBump returns true if the pre-release version v is already a release
+// of at least the bump.
+func coversBump(v version, bump Bump) bool {
+	switch bump {
+	case BumpMajor:
+		return v.minor == 0 && v.patch == 0
+	case BumpMinor:
+		return v.patch == 0
+	default:
+		return true
+	}
+}
+
+// prereleaseNumber returns N from a pre-release of the form "<id>.N".
+func prereleaseNumber(prerelease, id string) (int, bool) {
+	if !strings.HasPrefix(prerelease, id+".") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(prerelease, id+"."))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
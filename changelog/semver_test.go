@@ -0,0 +1,69 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestBump(t *testing.T) {
+	rules := []BumpRule{
+		{Type: "deprecation", Bump: BumpMinor},
+		{Type: "feature", Bump: BumpPatch},
+	}
+
+	for i, c := range []struct {
+		expected Bump
+		notes    []ReleaseNote
+	}{
+		{BumpPatch, nil},
+		{BumpPatch, []ReleaseNote{{Type: "bug"}, {}}},
+		{BumpPatch, []ReleaseNote{{Type: "feature"}}},
+		{BumpMinor, []ReleaseNote{{Type: "bug"}, {Type: "enhancement"}}},
+		{BumpMinor, []ReleaseNote{{Type: "deprecation"}}},
+		{BumpMajor, []ReleaseNote{{Type: "enhancement"}, {Type: "breaking-change"}}},
+		{BumpMajor, []ReleaseNote{{Type: "bug", BreakingChange: true}}},
+		{BumpPatch, []ReleaseNote{{Type: "none", BreakingChange: true}}},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.expected), func(t *testing.T) {
+			assert.Equal(t, c.expected, SuggestBump(c.notes, rules))
+		})
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	for i, c := range []struct {
+		expected   string
+		current    string
+		bump       Bump
+		prerelease string
+	}{
+		{"1.2.4", "1.2.3", BumpPatch, ""},
+		{"v1.3.0", "v1.2.3", BumpMinor, ""},
+		{"2.0.0", "1.2.3+build.5", BumpMajor, ""},
+		{"0.1.0-beta.1", "0.0.9", BumpMinor, "beta"},
+		// breaking changes only bump the minor version of 0.x
+		{"v0.4.0", "v0.3.1", BumpMajor, ""},
+		{"0.4.0", "0.4.0-beta.1", BumpMajor, ""},
+		{"0.4.0-beta.2", "0.4.0-beta.1", BumpMajor, "beta"},
+
+		{"1.3.0", "1.3.0-beta.2", BumpMinor, ""},
+		{"1.3.0", "1.3.0-beta.2", BumpPatch, ""},
+		{"2.0.0", "1.3.0-beta.2", BumpMajor, ""},
+		{"1.3.0-beta.3", "1.3.0-beta.2", BumpMinor, "beta"},
+		{"1.3.0-rc.1", "1.3.0-beta.2", BumpPatch, "rc"},
+		{"2.0.0-beta.1", "1.3.0-beta.2", BumpMajor, "beta"},
+		{"1.3.1", "1.3.1-beta", BumpPatch, ""},
+		{"1.3.1-beta.1", "1.3.1-beta", BumpPatch, "beta"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.current), func(t *testing.T) {
+			actual, err := NextVersion(c.current, c.bump, c.prerelease)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+
+	_, err := NextVersion("1.2", BumpPatch, "")
+	assert.Error(t, err)
+}
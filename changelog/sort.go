@@ -0,0 +1,160 @@
+package changelog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sort fields of release notes.
+const (
+	SortDate   = "date"
+	SortNumber = "number"
+	SortScope  = "scope"
+	SortText   = "text"
+	SortBlock  = "block"
+)
+
+// SortKey is a field release notes are ordered by.
+type SortKey struct {
+	// Field is one of SortDate (the merge time), SortNumber (the PR number),
+	// SortScope (the first label with Prefix, notes without one last),
+	// SortText (the text, ignoring case) or SortBlock (the position of the
+	// block in the PR body).
+	Field string
+
+	// Prefix is the label prefix of SortScope, like "service/".
+	Prefix string
+
+	Descending bool
+}
+
+func (k SortKey) String() string {
+	s := k.Field
+	if k.Field == SortScope && k.Prefix != "" {
+		s += ":" + k.Prefix
+	}
+	if k.Descending {
+		s = "-" + s
+	}
+	return s
+}
+
+// DefaultSortKeys order notes most recently merged first.
+var DefaultSortKeys = []SortKey{{Field: SortDate, Descending: true}}
+
+// ParseSortKey parses a sort field, prefixed with "-" for descending order.
+// The scope field takes the label prefix after a colon, like
+// "scope:service/".
+func ParseSortKey(s string) (SortKey, error) {
+	var k SortKey
+	if strings.HasPrefix(s, "-") {
+		k.Descending = true
+		s = s[1:]
+	}
+	k.Field = s
+	if strings.HasPrefix(s, SortScope+":") {
+		k.Field, k.Prefix = SortScope, strings.TrimPrefix(s, SortScope+":")
+	}
+
+	switch k.Field {
+	case SortDate, SortNumber, SortScope, SortText, SortBlock:
+		return k, nil
+	}
+	return SortKey{}, fmt.Errorf("invalid sort key %q, expected date, number, scope, text or block", s)
+}
+
+// sortTieBreakers are applied after the configured keys, so notes are always
+// in the same order.
+var sortTieBreakers = []SortKey{
+	{Field: SortNumber},
+	{Field: SortBlock},
+	{Field: SortText},
+}
+
+// sortReleaseNotes orders the notes by the keys, or DefaultSortKeys if none
+// are set.
+func sortReleaseNotes(notes []ReleaseNote, keys []SortKey) {
+	if len(keys) == 0 {
+		keys = DefaultSortKeys
+	}
+	keys = append(append([]SortKey{}, keys...), sortTieBreakers...)
+
+	sort.SliceStable(notes, func(i, j int) bool {
+		for _, k := range keys {
+			c := compareNotes(k, notes[i], notes[j])
+			if k.Descending {
+				c = -c
+			}
+			if c == 0 {
+				continue
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compareNotes returns -1, 0 or 1 as a is ordered before, with or after b by
+// the field of the key in ascending order. Notes without a scope are ordered
+// last in either direction.
+func compareNotes(k SortKey, a, b ReleaseNote) int {
+	switch k.Field {
+	case SortDate:
+		switch {
+		case a.PRDate.Before(b.PRDate):
+			return -1
+		case a.PRDate.After(b.PRDate):
+			return 1
+		}
+		return 0
+	case SortNumber:
+		return compareInts(a.PRNumber, b.PRNumber)
+	case SortBlock:
+		return compareInts(a.BlockIndex, b.BlockIndex)
+	case SortText:
+		return strings.Compare(strings.ToLower(a.Text), strings.ToLower(b.Text))
+	case SortScope:
+		sa, sb := noteScope(a, k.Prefix), noteScope(b, k.Prefix)
+		switch {
+		case sa == sb:
+			return 0
+		case sa == "" && k.Descending:
+			return -1
+		case sa == "":
+			return 1
+		case sb == "" && k.Descending:
+			return 1
+		case sb == "":
+			return -1
+		}
+		return strings.Compare(sa, sb)
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// noteScope returns the first label of the note with the prefix in
+// alphabetical order, without the prefix.
+func noteScope(note ReleaseNote, prefix string) string {
+	scope := ""
+	for _, l := range note.Labels {
+		if !strings.HasPrefix(l, prefix) {
+			continue
+		}
+		l = strings.TrimPrefix(l, prefix)
+		if l != "" && (scope == "" || l < scope) {
+			scope = l
+		}
+	}
+	return scope
+}
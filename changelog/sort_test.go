@@ -0,0 +1,72 @@
+package changelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSortKey(t *testing.T) {
+	for i, c := range []struct {
+		expected SortKey
+		s        string
+		err      bool
+	}{
+		{SortKey{Field: SortDate}, "date", false},
+		{SortKey{Field: SortDate, Descending: true}, "-date", false},
+		{SortKey{Field: SortScope}, "scope", false},
+		{SortKey{Field: SortScope, Prefix: "service/", Descending: true}, "-scope:service/", false},
+		{SortKey{}, "merged", true},
+		{SortKey{}, "text:foo", true},
+	} {
+		t.Run(fmt.Sprintf("%d %s", i, c.s), func(t *testing.T) {
+			actual, err := ParseSortKey(c.s)
+			if c.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+			assert.Equal(t, c.s, actual.String())
+		})
+	}
+}
+
+func TestSortReleaseNotes(t *testing.T) {
+	notes := []ReleaseNote{
+		{PRNumber: 3, PRDate: day(2), Text: "c", Labels: []string{"service/web"}},
+		{PRNumber: 1, PRDate: day(1), Text: "B", BlockIndex: 1, Labels: []string{"service/api", "bug"}},
+		{PRNumber: 1, PRDate: day(1), Text: "a", BlockIndex: 0, Labels: []string{"service/api", "bug"}},
+		{PRNumber: 2, PRDate: day(2), Text: "d"},
+	}
+
+	for i, c := range []struct {
+		expected []string
+		keys     []string
+	}{
+		{[]string{"d", "c", "a", "B"}, nil},
+		{[]string{"a", "B", "d", "c"}, []string{"date"}},
+		{[]string{"a", "B", "d", "c"}, []string{"number"}},
+		{[]string{"c", "d", "B", "a"}, []string{"-number", "-block"}},
+		{[]string{"a", "B", "c", "d"}, []string{"text"}},
+		{[]string{"a", "B", "c", "d"}, []string{"scope:service/"}},
+		{[]string{"c", "a", "B", "d"}, []string{"-scope:service/"}},
+		{[]string{"B", "a", "c", "d"}, []string{"scope:service/", "-text"}},
+		{[]string{"a", "d", "c", "B"}, []string{"block"}},
+	} {
+		t.Run(fmt.Sprintf("%d %v", i, c.keys), func(t *testing.T) {
+			var keys []SortKey
+			for _, s := range c.keys {
+				k, err := ParseSortKey(s)
+				assert.NoError(t, err)
+				keys = append(keys, k)
+			}
+
+			sorted := append([]ReleaseNote{}, notes...)
+			sortReleaseNotes(sorted, keys)
+
+			assert.Equal(t, c.expected, noteTexts(sorted))
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+// State records the results of a previous run so the next run only needs to
+// scan newer history. It is stored as JSON, see LoadState and Save.
+type State struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+
+	// Start and End are the range of history the state covers, runs for
+	// ranges starting earlier scan it again
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// LastCommit is the most recent commit scanned, and LastCommitTime its
+	// commit time
+	LastCommit     string    `json:"last_commit,omitempty"`
+	LastCommitTime time.Time `json:"last_commit_time,omitempty"`
+
+	// PullRequests are the PRs already processed, including those that did
+	// not produce any notes
+	PullRequests []ProcessedPullRequest `json:"pull_requests,omitempty"`
+
+	// Notes are the release notes of all processed PRs in the range, before
+	// the notes outside a run's range, reverted pairs and duplicates are
+	// dropped
+	Notes []ReleaseNote `json:"notes,omitempty"`
+}
+
+// ProcessedPullRequest is a PR recorded in a State, UpdatedAt is used to
+// detect PRs that were edited since they were processed.
+type ProcessedPullRequest struct {
+	ID        string    `json:"id"`
+	Number    int       `json:"number"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadState reads the state from filename, returning an empty state if the
+// file does not exist.
+func LoadState(filename string) (*State, error) {
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state State
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("error parsing state: %w", err)
+	}
+	return &state, nil
+}
+
+// Save writes the state to filename.
+func (s *State) Save(filename string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename so an interrupted run never
+	// leaves a partially written state
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// collectReleaseNotesIncremental returns the release notes for the PRs merged
+// between start and end, reusing the notes in the state. Only the history
+// after the last commit of the state is scanned, and previously processed PRs
+// are only fetched again if they were updated since. If start is before the
+// range of the state, the whole range is scanned again. The state is updated
+// with the results.
+func collectReleaseNotesIncremental(
+	ctx context.Context,
+	client *githubv4.Client,
+	logger hclog.Logger,
+	opts Options,
+	state *State,
+	start, end time.Time,
+) ([]ReleaseNote, error) {
+	if state.Owner == "" {
+		state.Owner, state.Repo, state.Branch = opts.Owner, opts.Repo, opts.Branch
+	}
+	if !strings.EqualFold(state.Owner, opts.Owner) || !strings.EqualFold(state.Repo, opts.Repo) || state.Branch != opts.Branch {
+		return nil, fmt.Errorf("state is for %s/%s@%s, not %s/%s@%s", state.Owner, state.Repo, state.Branch, opts.Owner, opts.Repo, opts.Branch)
+	}
+
+	if state.Start.IsZero() || start.Before(state.Start) {
+		if !state.Start.IsZero() {
+			logger.Info("state starts after the range, scanning again", "state_start", state.Start, "start", start)
+		}
+		*state = State{
+			Owner:  state.Owner,
+			Repo:   state.Repo,
+			Branch: state.Branch,
+			Start:  start,
+			End:    state.End,
+		}
+	}
+
+	since := state.LastCommitTime
+	if since.IsZero() {
+		since = state.Start
+	}
+	until := end
+	if state.End.After(until) {
+		until = state.End
+	}
+	logger = logger.With("since", since, "until", until)
+
+	processed := make(map[string]ProcessedPullRequest, len(state.PullRequests))
+	for _, pr := range state.PullRequests {
+		processed[pr.ID] = pr
+	}
+
+	logger.Info("checking commits for associated PRs", "last_commit", state.LastCommit)
+	commits, err := listCommits(ctx, client, opts.Owner, opts.Repo, opts.Branch, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pull requests: %w", err)
+	}
+
+	prIDs, err := commitPullRequestIDs(ctx, client, logger, opts, commits)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pull requests: %w", err)
+	}
+
+	var fetch []string
+	for _, id := range prIDs {
+		if _, ok := processed[id]; !ok {
+			fetch = append(fetch, id)
+		}
+	}
+	logger.Info("found new PRs", "count", len(fetch), "processed", len(processed))
+
+	edited, err := editedPullRequests(ctx, client, state.PullRequests)
+	if err != nil {
+		return nil, fmt.Errorf("error checking for edited pull requests: %w", err)
+	}
+	if len(edited) > 0 {
+		logger.Info("found edited PRs", "count", len(edited))
+	}
+	fetch = append(fetch, edited...)
+
+	prs, err := fetchPullRequests(ctx, client, logger, opts.Cache, fetch, opts.needsFiles())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving pull requests: %w", err)
+	}
+
+	// every fetched PR is recorded, including those with a no note label, so
+	// they are fetched again if their labels change. PRs that were fetched
+	// again have their notes replaced.
+	replaced := map[int]bool{}
+	record := func(prs []pullRequest) {
+		for _, pr := range prs {
+			replaced[pr.Number] = true
+			processed[pr.ID] = ProcessedPullRequest{
+				ID:        pr.ID,
+				Number:    pr.Number,
+				UpdatedAt: pr.UpdatedAt,
+			}
+		}
+	}
+	record(prs)
+	prs = dropNoNoteLabels(logger, opts, prs)
+
+	var backports map[int]backport
+	if opts.Backports {
+		prs, backports, err = resolveBackports(ctx, client, logger, opts, commits, prs)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving backports: %w", err)
+		}
+		record(prs)
+	}
+
+	notes := make([]ReleaseNote, 0, len(state.Notes))
+	previous := map[int]ReleaseNote{}
+	for _, n := range state.Notes {
+		if replaced[n.PRNumber] {
+			previous[n.PRNumber] = n
+			continue
+		}
+		notes = append(notes, n)
+	}
+
+	reverts, err := resolveReverts(ctx, client, logger, opts, prs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving reverts: %w", err)
+	}
+
+	newNotes := releaseNotesFromPullRequests(logger, opts, prs)
+	applyBackports(newNotes, backports)
+	applyReverts(newNotes, opts.Owner, opts.Repo, reverts)
+	for i, n := range newNotes {
+		// edited backported PRs are fetched without their backport, so keep
+		// the branch merge time and backport from when they were found
+		if p, ok := previous[n.PRNumber]; ok && p.Backport && !n.Backport {
+			newNotes[i].PRDate = p.PRDate
+			newNotes[i].Backport = true
+			newNotes[i].BackportPRNumber = p.BackportPRNumber
+			newNotes[i].BackportPRURL = p.BackportPRURL
+		}
+	}
+	notes = append(notes, newNotes...)
+	sortReleaseNotes(notes, DefaultSortKeys)
+
+	// notes outside the range are kept in the state for runs with a wider
+	// range
+	inRange := make([]ReleaseNote, 0, len(notes))
+	for _, n := range notes {
+		if n.PRDate.Before(start) || n.PRDate.After(end) {
+			continue
+		}
+		inRange = append(inRange, n)
+	}
+
+	inRange, err = finishReleaseNotes(logger, opts, inRange)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commits) > 0 && commits[0].CommittedDate.After(state.LastCommitTime) {
+		state.LastCommit = commits[0].OID
+		state.LastCommitTime = commits[0].CommittedDate
+	}
+	state.End = until
+	state.PullRequests = state.PullRequests[:0]
+	for _, pr := range processed {
+		state.PullRequests = append(state.PullRequests, pr)
+	}
+	sort.Slice(state.PullRequests, func(i, j int) bool {
+		return state.PullRequests[i].Number < state.PullRequests[j].Number
+	})
+	state.Notes = notes
+
+	return inRange, nil
+}
+
+// editedPullRequests returns the IDs of the processed PRs that have been
+// updated since they were processed.
+func editedPullRequests(ctx context.Context, client *githubv4.Client, processed []ProcessedPullRequest) ([]string, error) {
+	updatedAt := make(map[string]time.Time, len(processed))
+	ids := make([]string, 0, len(processed))
+	for _, pr := range processed {
+		updatedAt[pr.ID] = pr.UpdatedAt
+		ids = append(ids, pr.ID)
+	}
+
+	var edited []string
+	for _, chunkIDs := range chunk(ids, maxNodeIDs) {
+		var q struct {
+			Nodes []struct {
+				PullRequest struct {
+					ID        string
+					UpdatedAt time.Time
+				} `graphql:"... on PullRequest"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+
+		err := client.Query(ctx, &q, map[string]interface{}{
+			"ids": chunkIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range q.Nodes {
+			if !n.PullRequest.UpdatedAt.Equal(updatedAt[n.PullRequest.ID]) {
+				edited = append(edited, n.PullRequest.ID)
+			}
+		}
+	}
+	return edited, nil
+}
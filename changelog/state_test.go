@@ -0,0 +1,483 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+// day returns midnight UTC of the day in January 2020, the fixed dates used by
+// the fake history.
+func day(d int) time.Time {
+	return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+// noteTexts returns the text of each note.
+func noteTexts(notes []ReleaseNote) []string {
+	var texts []string
+	for _, n := range notes {
+		texts = append(texts, n.Text)
+	}
+	return texts
+}
+
+// noteNumbers returns the PR number of each note.
+func noteNumbers(notes []ReleaseNote) []int {
+	var numbers []int
+	for _, n := range notes {
+		numbers = append(numbers, n.PRNumber)
+	}
+	return numbers
+}
+
+// fakeGraphQL answers the queries used to collect release notes from a fixed
+// set of commits and PRs, recording the PR node IDs fully fetched.
+type fakeGraphQL struct {
+	commits []map[string]interface{}
+	prs     map[string]map[string]interface{}
+	fetched []string
+
+	// withFiles records whether the changed files were requested by each
+	// full PR query
+	withFiles []bool
+
+	// otherBranch are the PR IDs merged into a branch other than main, keyed
+	// by commit SHA
+	otherBranch map[string]string
+
+	// bases are the base branches of PRs not merged into main, keyed by PR ID
+	bases map[string]string
+}
+
+func (f *fakeGraphQL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ids []string
+	if raw, ok := req.Variables["ids"].([]interface{}); ok {
+		for _, id := range raw {
+			ids = append(ids, id.(string))
+		}
+	}
+
+	var data interface{}
+	switch {
+	case strings.Contains(req.Query, "object(expression"):
+		var prs []interface{}
+		if id, ok := f.otherBranch[req.Variables["commit"].(string)]; ok {
+			prs = append(prs, map[string]interface{}{
+				"id":    id,
+				"state": "MERGED",
+				"baseRef": map[string]interface{}{
+					"name": "develop",
+					"repository": map[string]interface{}{
+						"name":  "bar",
+						"owner": map[string]interface{}{"login": "foo"},
+					},
+				},
+			})
+		}
+		data = map[string]interface{}{
+			"repository": map[string]interface{}{
+				"object": map[string]interface{}{
+					"associatedPullRequests": map[string]interface{}{"nodes": prs},
+				},
+			},
+		}
+	case strings.Contains(req.Query, "pullRequest(number"):
+		id := fmt.Sprintf("pr%v", req.Variables["number"])
+		var pr interface{}
+		if _, ok := f.prs[id]; ok {
+			pr = map[string]interface{}{"id": id}
+		}
+		data = map[string]interface{}{
+			"repository": map[string]interface{}{"pullRequest": pr},
+		}
+	case strings.Contains(req.Query, "issueOrPullRequest"):
+		id := fmt.Sprintf("pr%v", req.Variables["number"])
+		var pr interface{}
+		if p, ok := f.prs[id]; ok {
+			base, ok := f.bases[id]
+			if !ok {
+				base = "main"
+			}
+			pr = map[string]interface{}{
+				"id":     id,
+				"number": p["number"],
+				"state":  "MERGED",
+				"baseRef": map[string]interface{}{
+					"name": base,
+					"repository": map[string]interface{}{
+						"name":  "bar",
+						"owner": map[string]interface{}{"login": "foo"},
+					},
+				},
+			}
+		}
+		data = map[string]interface{}{
+			"repository": map[string]interface{}{"issueOrPullRequest": pr},
+		}
+	case strings.Contains(req.Query, "history("):
+		data = map[string]interface{}{
+			"repository": map[string]interface{}{
+				"ref": map[string]interface{}{
+					"target": map[string]interface{}{
+						"history": map[string]interface{}{"nodes": f.commits},
+					},
+				},
+			},
+		}
+	case strings.Contains(req.Query, "associatedPullRequests"):
+		var nodes []interface{}
+		for _, id := range ids {
+			number, _ := strconv.Atoi(strings.TrimPrefix(id, "commit"))
+			nodes = append(nodes, map[string]interface{}{
+				"oid": fmt.Sprintf("oid%d", number),
+				"associatedPullRequests": map[string]interface{}{
+					"nodes": []interface{}{
+						map[string]interface{}{
+							"id":     fmt.Sprintf("pr%d", number),
+							"number": number,
+							"state":  "MERGED",
+							"baseRef": map[string]interface{}{
+								"name": "main",
+								"repository": map[string]interface{}{
+									"name":  "bar",
+									"owner": map[string]interface{}{"login": "foo"},
+								},
+							},
+						},
+					},
+				},
+			})
+		}
+		data = map[string]interface{}{"nodes": nodes}
+	case strings.Contains(req.Query, "nodes(ids") && !strings.Contains(req.Query, "title"):
+		var nodes []interface{}
+		for _, id := range ids {
+			nodes = append(nodes, map[string]interface{}{
+				"id":        id,
+				"updatedAt": f.prs[id]["updatedAt"],
+			})
+		}
+		data = map[string]interface{}{"nodes": nodes}
+	case strings.Contains(req.Query, "nodes(ids"):
+		withFiles, _ := req.Variables["withFiles"].(bool)
+		f.withFiles = append(f.withFiles, withFiles)
+
+		var nodes []interface{}
+		for _, id := range ids {
+			f.fetched = append(f.fetched, id)
+			pr := make(map[string]interface{}, len(f.prs[id]))
+			for k, v := range f.prs[id] {
+				if k != "files" || withFiles {
+					pr[k] = v
+				}
+			}
+			nodes = append(nodes, pr)
+		}
+		data = map[string]interface{}{"nodes": nodes}
+	default:
+		http.Error(w, "unexpected query: "+req.Query, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (f *fakeGraphQL) addPR(number int, title string, mergedAt time.Time) {
+	id := fmt.Sprintf("pr%d", number)
+	f.commits = append([]map[string]interface{}{{
+		"id":            fmt.Sprintf("commit%d", number),
+		"oid":           fmt.Sprintf("oid%d", number),
+		"committedDate": mergedAt,
+	}}, f.commits...)
+	f.prs[id] = map[string]interface{}{
+		"id":                      id,
+		"number":                  number,
+		"title":                   title,
+		"body":                    "",
+		"url":                     fmt.Sprintf("https://github.com/foo/bar/pull/%d", number),
+		"mergedAt":                mergedAt,
+		"updatedAt":               mergedAt,
+		"author":                  map[string]interface{}{"__typename": "User", "login": "foo", "url": "https://github.com/foo"},
+		"labels":                  map[string]interface{}{"nodes": []interface{}{}},
+		"files":                   map[string]interface{}{"nodes": []interface{}{}, "pageInfo": map[string]interface{}{}},
+		"commits":                 map[string]interface{}{"nodes": []interface{}{}},
+		"mergeCommit":             map[string]interface{}{"oid": fmt.Sprintf("oid%d", number), "abbreviatedOid": fmt.Sprintf("oid%d", number)},
+		"milestone":               nil,
+		"additions":               number,
+		"deletions":               1,
+		"changedFiles":            1,
+		"reviews":                 map[string]interface{}{"nodes": []interface{}{}},
+		"closingIssuesReferences": map[string]interface{}{"nodes": []interface{}{}},
+	}
+}
+
+func TestCollectReleaseNotes_incremental(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "second", day(3))
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{Owner: "foo", Repo: "bar", Branch: "main", State: &State{}}
+
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"second", "first"}, noteTexts(notes))
+	assert.Equal(t, "oid2", opts.State.LastCommit)
+	assert.Len(t, api.fetched, 2)
+
+	// save and reload the state as a separate run would
+	dir, err := ioutil.TempDir("", "changelog-gen-state")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "state.json")
+	assert.NoError(t, opts.State.Save(filename))
+	opts.State, err = LoadState(filename)
+	assert.NoError(t, err)
+
+	api.fetched = nil
+	api.addPR(3, "third", day(4))
+	api.prs["pr1"]["title"] = "first, edited"
+	api.prs["pr1"]["updatedAt"] = day(5)
+
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"third", "second", "first, edited"}, noteTexts(notes))
+	assert.ElementsMatch(t, []string{"pr1", "pr3"}, api.fetched)
+	assert.Equal(t, "oid3", opts.State.LastCommit)
+	assert.Len(t, opts.State.PullRequests, 3)
+
+	_, err = CollectReleaseNotes(ctx, client, logger, Options{Owner: "foo", Repo: "baz", Branch: "main", State: opts.State}, day(1), day(10))
+	assert.EqualError(t, err, "state is for foo/bar@main, not foo/baz@main")
+}
+
+func TestLoadState_missing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "changelog-gen-state")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	state, err := LoadState(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, &State{}, state)
+
+	filename := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("{"), 0644))
+	_, err = LoadState(filename)
+	assert.Error(t, err)
+}
+
+func TestCollectReleaseNotes_incrementalBackport(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "first, backported", day(3))
+	api.prs["pr2"]["body"] = "Backport of #1"
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{Owner: "foo", Repo: "bar", Branch: "main", State: &State{}}
+
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, noteNumbers(notes))
+
+	dir, err := ioutil.TempDir("", "changelog-gen-state")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "state.json")
+	assert.NoError(t, opts.State.Save(filename))
+	opts.State, err = LoadState(filename)
+	assert.NoError(t, err)
+
+	// the backport's note is read from the state, and still dropped as a
+	// duplicate of its original
+	api.addPR(3, "third", day(4))
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 1}, noteNumbers(notes))
+}
+
+func TestCollectReleaseNotes_relabeledCached(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "second", day(3))
+	api.prs["pr1"]["labels"] = map[string]interface{}{"nodes": []interface{}{
+		map[string]interface{}{"name": "no-release-note"},
+	}}
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "changelog-gen-cache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{
+		Owner:        "foo",
+		Repo:         "bar",
+		Branch:       "main",
+		NoNoteLabels: []string{"no-release-note"},
+		Cache:        NewCache(dir),
+	}
+
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2}, noteNumbers(notes))
+
+	// the commit associations are cached, but the label change updates the PR
+	api.prs["pr1"]["labels"] = map[string]interface{}{"nodes": []interface{}{}}
+	api.prs["pr1"]["updatedAt"] = day(4)
+
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, noteNumbers(notes))
+}
+
+func TestCollectReleaseNotes_paths(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "api", day(2))
+	api.addPR(2, "docs", day(3))
+	api.prs["pr1"]["files"] = map[string]interface{}{
+		"nodes":    []interface{}{map[string]interface{}{"path": "services/api/main.go"}},
+		"pageInfo": map[string]interface{}{},
+	}
+	api.prs["pr2"]["files"] = map[string]interface{}{
+		"nodes":    []interface{}{map[string]interface{}{"path": "docs/index.md"}},
+		"pageInfo": map[string]interface{}{},
+	}
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{Owner: "foo", Repo: "bar", Branch: "main"}
+
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Len(t, notes, 2)
+	assert.Equal(t, []bool{false}, api.withFiles)
+
+	api.withFiles = nil
+	opts.Paths = PathFilter{Include: []string{"services/api"}}
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	if assert.Len(t, notes, 1) {
+		assert.Equal(t, "api", notes[0].Text)
+	}
+	assert.Equal(t, []bool{true}, api.withFiles)
+}
+
+func TestCollectReleaseNotes_incrementalRange(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "second", day(3))
+	api.addPR(3, "third", day(4))
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{Owner: "foo", Repo: "bar", Branch: "main", State: &State{}}
+
+	// the fake history is not limited to the range, so notes outside it are
+	// kept in the state
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(3), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"third", "second"}, noteTexts(notes))
+	assert.Equal(t, day(3), opts.State.Start)
+	assert.Equal(t, day(10), opts.State.End)
+	assert.Len(t, opts.State.Notes, 3)
+
+	// within the range of the state nothing is fetched, and the notes are
+	// filtered by both start and end
+	api.fetched = nil
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(3), day(3))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"second"}, noteTexts(notes))
+	assert.Empty(t, api.fetched)
+	assert.Len(t, opts.State.Notes, 3)
+
+	// starting before the state scans the whole range again
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"third", "second", "first"}, noteTexts(notes))
+	assert.ElementsMatch(t, []string{"pr1", "pr2", "pr3"}, api.fetched)
+	assert.Equal(t, day(1), opts.State.Start)
+	assert.Equal(t, day(10), opts.State.End)
+}
+
+func TestCollectReleaseNotes_incrementalRelabeled(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "second", day(3))
+	api.prs["pr2"]["labels"] = map[string]interface{}{"nodes": []interface{}{
+		map[string]interface{}{"name": "no-release-note"},
+	}}
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	logger := hclog.NewNullLogger()
+	opts := Options{
+		Owner:        "foo",
+		Repo:         "bar",
+		Branch:       "main",
+		NoNoteLabels: []string{"no-release-note"},
+		State:        &State{},
+	}
+
+	notes, err := CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, noteNumbers(notes))
+	assert.Len(t, opts.State.PullRequests, 2)
+
+	// removing the label updates the recorded PR, so it is fetched again
+	api.fetched = nil
+	api.prs["pr2"]["labels"] = map[string]interface{}{"nodes": []interface{}{}}
+	api.prs["pr2"]["updatedAt"] = day(4)
+
+	notes, err = CollectReleaseNotes(ctx, client, logger, opts, day(1), day(10))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, noteNumbers(notes))
+	assert.Equal(t, []string{"pr2"}, api.fetched)
+}
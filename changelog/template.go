@@ -1,8 +1,11 @@
 package changelog
 
 import (
+	"fmt"
 	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/Masterminds/sprig"
 )
@@ -13,7 +16,7 @@ const defaultChangelogTemplate = `
 {{- $features := newStringList -}}
 {{- $improvements := newStringList -}}
 {{- $bugs := newStringList -}}
-{{- range . -}}
+{{- range .Notes -}}
   {{if .BreakingChange -}}
 	{{$breaking = append $breaking (renderReleaseNote .) -}}
   {{else if or (has "new-resource" .Labels) (has "new-data-source" .Labels) -}}
@@ -27,28 +30,28 @@ const defaultChangelogTemplate = `
 {{- if gt (len $breaking) 0 -}}
 BREAKING CHANGES
 
-{{range $breaking | sortAlpha -}}
+{{range $breaking -}}
 * {{. }}
 {{end -}}
 {{- end -}}
 {{- if gt (len $features) 0}}
 FEATURES
 
-{{range $features | sortAlpha -}}
+{{range $features -}}
 * {{. }}
 {{end -}}
 {{- end -}}
 {{- if gt (len $improvements) 0}}
 IMPROVEMENTS
 
-{{range $improvements | sortAlpha -}}
+{{range $improvements -}}
 * {{. }}
 {{end -}}
 {{- end -}}
 {{- if gt (len $bugs) 0}}
 BUGS
 
-{{range $bugs | sortAlpha -}}
+{{range $bugs -}}
 * {{. }}
 {{end -}}
 {{- end -}}
@@ -78,15 +81,126 @@ func renderReleaseNoteFunc(templateText string) func(ReleaseNote) (string, error
 	}
 }
 
-func renderChangelog(changelogTemplateText, releaseNoteTemplateText string, notes []ReleaseNote) (string, error) {
+// ChangelogData is the root of the changelog template.
+type ChangelogData struct {
+	// Notes are the release notes of the changelog
+	Notes []ReleaseNote
+
+	// Contributors are the authors of the notes, if nil they are derived
+	// from the notes
+	Contributors []Contributor
+
+	// Owner, Repo and Branch are the repository the changelog is for
+	Owner  string
+	Repo   string
+	Branch string
+
+	// Component is the name of the monorepo component the changelog is for,
+	// if any
+	Component string
+
+	// StartRef and EndRef are the commits or tags at the start and end of
+	// the range, they are empty if the range was given as times
+	StartRef string
+	EndRef   string
+
+	// Start and End are the times of the range
+	Start time.Time
+	End   time.Time
+
+	// Version is the version of the release, it defaults to NextVersion
+	Version string
+
+	// NextVersion is the suggested version of the release, it is empty if
+	// the current version is not known
+	NextVersion string
+
+	// Generated is when the changelog was rendered
+	Generated time.Time
+}
+
+// RepoURL returns the GitHub URL of the repository.
+func (d ChangelogData) RepoURL() string {
+	if d.Owner == "" || d.Repo == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://github.com/%s/%s", d.Owner, d.Repo)
+}
+
+// CompareURL returns the GitHub URL comparing the start and end refs, it is
+// empty if either ref is unknown.
+func (d ChangelogData) CompareURL() string {
+	if d.RepoURL() == "" || d.StartRef == "" || d.EndRef == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/compare/%s...%s", d.RepoURL(), d.StartRef, d.EndRef)
+}
+
+func renderChangelog(changelogTemplateText, releaseNoteTemplateText string, data ChangelogData) (string, error) {
+	if data.Contributors == nil {
+		data.Contributors = contributorsFromNotes(data.Notes)
+	}
+
 	funcs := template.FuncMap{
 		"renderReleaseNote": renderReleaseNoteFunc(releaseNoteTemplateText),
+		"contributors": func() []Contributor {
+			return data.Contributors
+		},
 	}
 
-	return render(changelogTemplateText, notes, funcs)
+	tmpl, err := parseTemplate(changelogTemplateText, funcs)
+	if err != nil {
+		return "", err
+	}
+
+	// templates written before the root had fields range over the notes
+	if rangesOverDot(tmpl.Tree.Root) {
+		return execute(tmpl, data.Notes)
+	}
+	return execute(tmpl, data)
+}
+
+// rangesOverDot returns true if the template ranges over its root, outside of
+// any range or with that rebinds dot.
+func rangesOverDot(list *parse.ListNode) bool {
+	if list == nil {
+		return false
+	}
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.RangeNode:
+			cmds := n.Pipe.Cmds
+			if len(cmds) == 1 && len(cmds[0].Args) == 1 {
+				if _, ok := cmds[0].Args[0].(*parse.DotNode); ok {
+					return true
+				}
+			}
+			// dot is rebound within the range, but not its else branch
+			if rangesOverDot(n.ElseList) {
+				return true
+			}
+		case *parse.IfNode:
+			if rangesOverDot(n.List) || rangesOverDot(n.ElseList) {
+				return true
+			}
+		case *parse.WithNode:
+			if rangesOverDot(n.ElseList) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func render(templateText string, data interface{}, additionalFuncs template.FuncMap) (string, error) {
+	tmpl, err := parseTemplate(templateText, additionalFuncs)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+func parseTemplate(templateText string, additionalFuncs template.FuncMap) (*template.Template, error) {
 	const renderTemplateName = "render"
 
 	tmpl := template.New(renderTemplateName)
@@ -98,14 +212,12 @@ func render(templateText string, data interface{}, additionalFuncs template.Func
 	funcs["newStringList"] = newStringList
 	tmpl = tmpl.Funcs(funcs)
 
-	var err error
-	tmpl, err = tmpl.Parse(templateText)
-	if err != nil {
-		return "", err
-	}
+	return tmpl.Parse(templateText)
+}
 
+func execute(tmpl *template.Template, data interface{}) (string, error) {
 	builder := &strings.Builder{}
-	err = tmpl.Execute(builder, data)
+	err := tmpl.Execute(builder, data)
 	if err != nil {
 		return "", err
 	}
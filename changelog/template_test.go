@@ -3,6 +3,7 @@ package changelog
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -56,13 +57,13 @@ BUGS
 func TestRender_defaultChangelogTemplate(t *testing.T) {
 	expected := `BREAKING CHANGES
 
-* this is a breaking bug ([0]() by []())
 * this is a breaking feature ([0]() by []())
+* this is a breaking bug ([0]() by []())
 
 FEATURES
 
-* this is a new data-source ([0]() by []())
 * this is a new resource ([0]() by []())
+* this is a new data-source ([0]() by []())
 
 IMPROVEMENTS
 
@@ -73,7 +74,7 @@ BUGS
 * this is a bug ([0]() by []())
 `
 
-	actual, err := renderChangelog(defaultChangelogTemplate, defaultReleaseNoteTemplate, []ReleaseNote{
+	actual, err := renderChangelog(defaultChangelogTemplate, defaultReleaseNoteTemplate, ChangelogData{Notes: []ReleaseNote{
 		{
 			BreakingChange: true,
 			Text:           "this is a breaking feature",
@@ -98,7 +99,7 @@ BUGS
 			Bug:  true,
 			Text: "this is a bug",
 		},
-	})
+	}})
 	assert.NoError(t, err)
 	assert.Equal(t, expected, actual)
 }
@@ -122,7 +123,7 @@ BUGS
 * this is a bug ([0]() by []())
 `
 
-	actual, err := renderChangelog(defaultBlockTypeChangelogTemplate, defaultReleaseNoteTemplate, []ReleaseNote{
+	actual, err := renderChangelog(defaultBlockTypeChangelogTemplate, defaultReleaseNoteTemplate, ChangelogData{Notes: []ReleaseNote{
 		{
 			Type: "breaking-change",
 			Text: "this is a breaking change",
@@ -143,7 +144,7 @@ BUGS
 			Type: "bug",
 			Text: "this is a bug",
 		},
-	})
+	}})
 	assert.NoError(t, err)
 	assert.Equal(t, expected, actual)
 }
@@ -214,3 +215,77 @@ func TestRender_defaultReleaseNoteTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestRender_contributors(t *testing.T) {
+	const changelogTemplate = `{{range contributors}}{{.Login}} ({{.PRCount}}){{if .FirstTime}} first time{{end}}
+{{end}}`
+
+	notes := []ReleaseNote{
+		{Author: "foo", PRNumber: 1},
+		{Author: "bar", PRNumber: 2},
+		{Author: "foo", PRNumber: 3},
+	}
+
+	actual, err := renderChangelog(changelogTemplate, defaultReleaseNoteTemplate, ChangelogData{Notes: notes})
+	assert.NoError(t, err)
+	assert.Equal(t, "bar (1)\nfoo (2)\n", actual)
+
+	actual, err = renderChangelog(changelogTemplate, defaultReleaseNoteTemplate, ChangelogData{
+		Notes: notes,
+		Contributors: []Contributor{
+			{Login: "foo", PRCount: 2, FirstTime: true},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "foo (2) first time\n", actual)
+}
+
+func TestRender_nextVersion(t *testing.T) {
+	notes := []ReleaseNote{{Text: "foo"}, {Text: "bar"}}
+
+	for i, c := range []struct {
+		expected string
+		template string
+	}{
+		{"v1.1.0: 2", `{{.NextVersion}}: {{len .Notes}}`},
+		{"foo,bar,", `{{range .}}{{.Text}},{{end}}`},
+		{"foo,bar,", `{{if true}}{{range .}}{{.Text}},{{end}}{{end}}`},
+		{"foo,bar,", `{{range .Notes}}{{.Text}},{{end}}`},
+		{"v1.1.0", `{{with .NextVersion}}{{range $.Notes}}{{end}}{{.}}{{end}}`},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			actual, err := renderChangelog(c.template, defaultReleaseNoteTemplate, ChangelogData{
+				Notes:       notes,
+				NextVersion: "v1.1.0",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+
+	// errors are reported rather than retried with the notes as the root
+	_, err := renderChangelog(`{{.Missing}}`, defaultReleaseNoteTemplate, ChangelogData{Notes: notes})
+	assert.Contains(t, err.Error(), "ChangelogData")
+	_, err = renderChangelog(`{{len .}}`, defaultReleaseNoteTemplate, ChangelogData{Notes: notes})
+	assert.Error(t, err)
+}
+
+func TestRender_metadata(t *testing.T) {
+	const changelogTemplate = `## [{{.Version}}]({{.CompareURL}}) ({{.End.Format "2006-01-02"}})
+{{.RepoURL}} {{.Branch}} {{.StartRef}}..{{.EndRef}} {{len .Notes}}`
+
+	actual, err := renderChangelog(changelogTemplate, defaultReleaseNoteTemplate, ChangelogData{
+		Notes:    []ReleaseNote{{Text: "foo"}},
+		Owner:    "foo",
+		Repo:     "bar",
+		Branch:   "main",
+		StartRef: "v1.0.0",
+		EndRef:   "v1.1.0",
+		End:      time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC),
+		Version:  "v1.1.0",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "## [v1.1.0](https://github.com/foo/bar/compare/v1.0.0...v1.1.0) (2020-03-04)\nhttps://github.com/foo/bar main v1.0.0..v1.1.0 1", actual)
+
+	assert.Equal(t, "", ChangelogData{Owner: "foo", Repo: "bar", EndRef: "v1.1.0"}.CompareURL())
+}
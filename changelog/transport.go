@@ -0,0 +1,429 @@
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+const (
+	defaultMaxRetries = 5
+	defaultMaxWait    = time.Hour
+	retryBaseDelay    = time.Second
+	retryMaxDelay     = time.Minute
+)
+
+// RateLimitUsage summarizes the GitHub API rate limit budget used by requests
+// made through a Transport.
+type RateLimitUsage struct {
+	Requests int
+	Retries  int
+
+	// Used is the number of rate limit points consumed, based on the
+	// X-RateLimit-Used header of each response.
+	Used int
+
+	// Remaining, Limit and Reset are from the most recent response, for the
+	// rate limit resource it was counted against.
+	Remaining int
+	Limit     int
+	Reset     time.Time
+}
+
+// Transport is an http.RoundTripper for the GitHub API that tracks the rate
+// limit budget, waits for the rate limit to reset when it is exhausted and
+// retries secondary rate limits and transient server errors with jittered
+// exponential backoff.
+type Transport struct {
+	// Base is the underlying transport, http.DefaultTransport if nil.
+	Base http.RoundTripper
+
+	Logger hclog.Logger
+
+	// MaxRetries is the number of times a request is retried, defaults to 5.
+	MaxRetries int
+
+	// MaxWait is the longest the transport will wait for a single retry,
+	// defaults to one hour.
+	MaxWait time.Duration
+
+	// sleep and now are replaced in tests
+	sleep func(context.Context, time.Duration) error
+	now   func() time.Time
+
+	mu     sync.Mutex
+	usage  RateLimitUsage
+	limits map[string]*rateLimit
+}
+
+// rateLimit is the last known state of a single rate limit resource, GitHub
+// has separate limits for the REST API ("core"), GraphQL and search.
+type rateLimit struct {
+	remaining int
+	used      int
+	reset     time.Time
+}
+
+// NewTransport returns a Transport wrapping base.
+func NewTransport(base http.RoundTripper, logger hclog.Logger) *Transport {
+	return &Transport{
+		Base:   base,
+		Logger: logger,
+	}
+}
+
+// Usage returns the rate limit usage so far.
+func (t *Transport) Usage() RateLimitUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// LogUsage logs the rate limit usage so far.
+func (t *Transport) LogUsage() {
+	u := t.Usage()
+	if u.Requests == 0 {
+		return
+	}
+	t.logger().Info("GitHub API usage",
+		"requests", u.Requests,
+		"retries", u.Retries,
+		"used", u.Used,
+		"remaining", u.Remaining,
+		"limit", u.Limit,
+		"reset", u.Reset,
+	)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.waitForReset(req.Context(), requestResource(req)); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.base().RoundTrip(attemptReq)
+		if err == nil {
+			t.track(resp)
+		}
+
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait, reason, retry := t.shouldRetry(resp, err, attempt)
+		if retry && !rateLimited(reason) && !isIdempotent(req, body) {
+			// the request may have taken effect, so only retry once GitHub
+			// says it did nothing
+			retry = false
+		}
+		if !retry || attempt >= maxRetries {
+			return resp, err
+		}
+
+		if wait > t.maxWait() {
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		if resp != nil {
+			// drain so the connection can be reused
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		t.mu.Lock()
+		t.usage.Retries++
+		t.mu.Unlock()
+
+		logger := t.logger().With("attempt", attempt+1, "reason", reason, "wait", wait)
+		if err != nil {
+			logger = logger.With("err", err)
+		}
+		logger.Warn("retrying GitHub request")
+
+		if err := t.doSleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rateLimitReasons are the retry reasons that guarantee GitHub did not act on
+// the request.
+var rateLimitReasons = []string{"secondary rate limit", "rate limit exhausted"}
+
+func rateLimited(reason string) bool {
+	return stringInSlice(rateLimitReasons, reason)
+}
+
+// isIdempotent returns true if repeating the request has no additional
+// effect: requests of idempotent methods, requests marked with an
+// Idempotency-Key header, and GraphQL queries (but not mutations).
+func isIdempotent(req *http.Request, body []byte) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	if req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != "" {
+		return true
+	}
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/graphql") {
+		var q struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(body, &q); err != nil {
+			return false
+		}
+		return !strings.HasPrefix(strings.TrimSpace(q.Query), "mutation")
+	}
+	return false
+}
+
+// shouldRetry determines if a request should be retried, and how long to wait
+// before doing so.
+func (t *Transport) shouldRetry(resp *http.Response, err error, attempt int) (time.Duration, string, bool) {
+	if err != nil {
+		return backoff(attempt), "request error", true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if d, ok := retryAfter(resp); ok {
+			return d, "secondary rate limit", true
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return t.untilReset(responseResource(resp)), "rate limit exhausted", true
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || isSecondaryRateLimit(resp) {
+			return backoff(attempt), "secondary rate limit", true
+		}
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return backoff(attempt), fmt.Sprintf("status %d", resp.StatusCode), true
+	case http.StatusOK:
+		// GraphQL reports an exhausted primary rate limit as an error in a
+		// successful response
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" && bodyContains(resp, `"RATE_LIMITED"`) {
+			return t.untilReset(responseResource(resp)), "rate limit exhausted", true
+		}
+	}
+
+	return 0, "", false
+}
+
+// requestResource returns the rate limit resource a request will be counted
+// against.
+func requestResource(req *http.Request) string {
+	switch {
+	case req == nil:
+		return "core"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case strings.HasPrefix(strings.TrimPrefix(req.URL.Path, "/api/v3"), "/search/"):
+		return "search"
+	}
+	return "core"
+}
+
+// responseResource returns the rate limit resource of a response, from its
+// X-RateLimit-Resource header or else its request.
+func responseResource(resp *http.Response) string {
+	if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+		return r
+	}
+	return requestResource(resp.Request)
+}
+
+// track records the rate limit headers of a response.
+func (t *Transport) track(resp *http.Response) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.usage.Requests++
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	used, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	var reset time.Time
+	if epoch, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(epoch, 0)
+	}
+
+	if t.limits == nil {
+		t.limits = map[string]*rateLimit{}
+	}
+	resource := responseResource(resp)
+	l, ok := t.limits[resource]
+	switch {
+	case !ok:
+		// the cost of the first request is unknown, GraphQL queries cost
+		// at least a single point
+		l = &rateLimit{}
+		t.limits[resource] = l
+		t.usage.Used++
+	case !reset.Equal(l.reset):
+		// the rate limit window reset between responses
+		t.usage.Used += used
+	case used > l.used:
+		t.usage.Used += used - l.used
+	}
+
+	l.remaining = remaining
+	l.used = used
+	l.reset = reset
+
+	t.usage.Remaining = remaining
+	t.usage.Limit = limit
+	t.usage.Reset = reset
+}
+
+// waitForReset blocks until the rate limit of the resource resets if the last
+// response for it indicated it was exhausted.
+func (t *Transport) waitForReset(ctx context.Context, resource string) error {
+	t.mu.Lock()
+	l, ok := t.limits[resource]
+	exhausted := ok && l.remaining == 0 && !l.reset.IsZero()
+	t.mu.Unlock()
+	if !exhausted {
+		return nil
+	}
+
+	wait := t.untilReset(resource)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > t.maxWait() {
+		return fmt.Errorf("GitHub %s rate limit exhausted until %s", resource, t.currentTime().Add(wait).Format(time.RFC3339))
+	}
+
+	t.logger().Warn("GitHub rate limit exhausted, waiting for reset", "resource", resource, "wait", wait)
+	return t.doSleep(ctx, wait)
+}
+
+func (t *Transport) untilReset(resource string) time.Duration {
+	t.mu.Lock()
+	var reset time.Time
+	if l, ok := t.limits[resource]; ok {
+		reset = l.reset
+	}
+	t.mu.Unlock()
+
+	if reset.IsZero() {
+		return retryMaxDelay
+	}
+	// pad slightly to account for clock skew
+	return reset.Sub(t.currentTime()) + time.Second
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) logger() hclog.Logger {
+	if t.Logger != nil {
+		return t.Logger
+	}
+	return hclog.NewNullLogger()
+}
+
+func (t *Transport) maxWait() time.Duration {
+	if t.MaxWait > 0 {
+		return t.MaxWait
+	}
+	return defaultMaxWait
+}
+
+func (t *Transport) currentTime() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+func (t *Transport) doSleep(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns an exponential delay for the attempt, randomized between
+// half and all of the delay.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << uint(attempt)
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func isSecondaryRateLimit(resp *http.Response) bool {
+	return bodyContains(resp, "secondary rate limit") || bodyContains(resp, "abuse detection")
+}
+
+// bodyContains checks the response body for s, leaving the body readable.
+func bodyContains(resp *http.Response, s string) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return bytes.Contains(body, []byte(s))
+}
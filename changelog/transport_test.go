@@ -0,0 +1,270 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTransport returns a transport with a fake clock starting at now that
+// is advanced by sleeps instead of blocking.
+func newTestTransport(now time.Time) (*Transport, *[]time.Duration) {
+	var sleeps []time.Duration
+	t := NewTransport(nil, nil)
+	t.now = func() time.Time { return now }
+	t.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return nil
+	}
+	return t, &sleeps
+}
+
+func TestTransport_retry(t *testing.T) {
+	now := time.Now()
+	reset := fmt.Sprintf("%d", now.Add(30*time.Minute).Unix())
+
+	for i, c := range []struct {
+		responses     []func(w http.ResponseWriter)
+		expectedCode  int
+		expectedCalls int
+		checkSleeps   func(t *testing.T, sleeps []time.Duration)
+	}{
+		// success
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  200,
+			expectedCalls: 1,
+		},
+		// transient errors
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(503) },
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  200,
+			expectedCalls: 3,
+			checkSleeps: func(t *testing.T, sleeps []time.Duration) {
+				assert.Len(t, sleeps, 2)
+			},
+		},
+		// not retried
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(401) },
+			},
+			expectedCode:  401,
+			expectedCalls: 1,
+		},
+		// secondary rate limit with retry-after
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("Retry-After", "30")
+					w.WriteHeader(403)
+				},
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  200,
+			expectedCalls: 2,
+			checkSleeps: func(t *testing.T, sleeps []time.Duration) {
+				assert.Equal(t, []time.Duration{30 * time.Second}, sleeps)
+			},
+		},
+		// secondary rate limit without retry-after
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.WriteHeader(403)
+					w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+				},
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  200,
+			expectedCalls: 2,
+		},
+		// primary rate limit exhausted
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", reset)
+					w.Write([]byte(`{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`))
+				},
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  200,
+			expectedCalls: 2,
+			checkSleeps: func(t *testing.T, sleeps []time.Duration) {
+				assert.Len(t, sleeps, 1)
+				assert.InDelta(t, float64(30*time.Minute), float64(sleeps[0]), float64(5*time.Second))
+			},
+		},
+		// gives up after max retries
+		{
+			responses: []func(w http.ResponseWriter){
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) { w.WriteHeader(502) },
+				func(w http.ResponseWriter) {},
+			},
+			expectedCode:  502,
+			expectedCalls: 6,
+		},
+	} {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, `{"query":"{viewer{login}}"}`, string(body))
+				c.responses[calls](w)
+				calls++
+			}))
+			defer srv.Close()
+
+			transport, sleeps := newTestTransport(now)
+
+			req, err := http.NewRequest("POST", srv.URL+"/graphql", strings.NewReader(`{"query":"{viewer{login}}"}`))
+			assert.NoError(t, err)
+			resp, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, c.expectedCode, resp.StatusCode)
+			assert.Equal(t, c.expectedCalls, calls)
+			if c.checkSleeps != nil {
+				c.checkSleeps(t, *sleeps)
+			}
+		})
+	}
+}
+
+func TestTransport_retryNonIdempotent(t *testing.T) {
+	for i, c := range []struct {
+		method        string
+		path          string
+		body          string
+		header        string
+		status        int
+		expectedCalls int
+	}{
+		// creating a release or comment may have succeeded
+		{"POST", "/repos/foo/bar/releases", "{}", "", 502, 1},
+		{"POST", "/graphql", `{"query":"mutation{foo}"}`, "", 502, 1},
+		{"POST", "/repos/foo/bar/releases", "{}", "Idempotency-Key", 502, 2},
+		{"PATCH", "/repos/foo/bar/releases/1", "{}", "", 502, 2},
+		{"GET", "/repos/foo/bar/releases", "", "", 502, 2},
+		// rate limited requests were not acted on
+		{"POST", "/repos/foo/bar/releases", "{}", "", 429, 2},
+	} {
+		t.Run(fmt.Sprintf("%d %s %s", i, c.method, c.path), func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(c.status)
+				}
+			}))
+			defer srv.Close()
+
+			transport, _ := newTestTransport(time.Now())
+
+			req, err := http.NewRequest(c.method, srv.URL+c.path, strings.NewReader(c.body))
+			assert.NoError(t, err)
+			if c.header != "" {
+				req.Header.Set(c.header, "key")
+			}
+			resp, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, c.expectedCalls, calls)
+		})
+	}
+}
+
+func TestTransport_usage(t *testing.T) {
+	used := 10
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		used += 2
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", 5000-used))
+		w.Header().Set("X-RateLimit-Used", fmt.Sprintf("%d", used))
+		w.Header().Set("X-RateLimit-Reset", "1600000000")
+	}))
+	defer srv.Close()
+
+	transport, _ := newTestTransport(time.Now())
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("POST", srv.URL, strings.NewReader("query"))
+		assert.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, RateLimitUsage{
+		Requests:  3,
+		Used:      5,
+		Remaining: 4984,
+		Limit:     5000,
+		Reset:     time.Unix(1600000000, 0),
+	}, transport.Usage())
+}
+
+func TestTransport_rateLimitResources(t *testing.T) {
+	now := time.Now()
+	reset := fmt.Sprintf("%d", now.Add(10*time.Minute).Unix())
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/graphql" {
+			w.Header().Set("X-RateLimit-Resource", "graphql")
+			w.Header().Set("X-RateLimit-Remaining", "100")
+			w.Header().Set("X-RateLimit-Used", "5")
+		} else {
+			w.Header().Set("X-RateLimit-Resource", "core")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Used", "5000")
+		}
+		w.Header().Set("X-RateLimit-Reset", reset)
+	}))
+	defer srv.Close()
+
+	transport, sleeps := newTestTransport(now)
+	do := func(method, path, body string) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		assert.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// the exhausted REST limit does not hold up GraphQL queries
+	do("GET", "/repos/foo/bar/releases", "")
+	do("POST", "/graphql", `{"query":"{viewer{login}}"}`)
+	do("POST", "/graphql", `{"query":"{viewer{login}}"}`)
+	assert.Empty(t, *sleeps)
+
+	// but the next REST request waits for its reset
+	do("GET", "/repos/foo/bar/releases", "")
+	if assert.Len(t, *sleeps, 1) {
+		assert.InDelta(t, float64(10*time.Minute), float64((*sleeps)[0]), float64(5*time.Second))
+	}
+
+	// the first response of each resource counts a point, and the used
+	// counts of the resources do not overwrite each other
+	assert.Equal(t, 2, transport.Usage().Used)
+}
@@ -0,0 +1,170 @@
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+)
+
+// Unreleased tracks the release notes of the PRs merged since the last
+// release. It is built with a full collection by Refresh and then updated
+// incrementally one PR at a time as PRs merge or change.
+type Unreleased struct {
+	client *githubv4.Client
+	logger hclog.Logger
+	opts   Options
+	start  time.Time
+
+	mu      sync.RWMutex
+	notes   []ReleaseNote
+	updated time.Time
+}
+
+// NewUnreleased returns a tracker for the PRs merged since start, it is empty
+// until Refresh is called.
+func NewUnreleased(client *githubv4.Client, logger hclog.Logger, opts Options, start time.Time) *Unreleased {
+	// a single note with an unknown type should not stop tracking
+	opts.Strict = false
+
+	return &Unreleased{
+		client: client,
+		logger: logger,
+		opts:   opts,
+		start:  start,
+	}
+}
+
+// Refresh collects the release notes of all PRs merged since the start.
+func (u *Unreleased) Refresh(ctx context.Context) error {
+	notes, err := CollectReleaseNotes(ctx, u.client, u.logger, u.opts, u.start, time.Now())
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.notes = notes
+	u.updated = time.Now().UTC()
+	return nil
+}
+
+// UpdatePullRequest recomputes the release notes of a single merged PR,
+// removing them if the PR now has one of the no note labels. Backports and
+// reverts are resolved as they are by CollectReleaseNotes.
+func (u *Unreleased) UpdatePullRequest(ctx context.Context, number int, labels []string) error {
+	logger := u.logger.With("pr", number)
+
+	var notes []ReleaseNote
+	if l, ok := firstInSlice(u.opts.NoNoteLabels, labels); ok {
+		logger.Debug("skipping PR", "reason", "no note label", "label", l)
+	} else {
+		var err error
+		notes, err = u.pullRequestNotes(ctx, logger, number)
+		if err != nil {
+			return err
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	// a backport PR's notes are those of its originals
+	merged := make([]ReleaseNote, 0, len(u.notes)+len(notes))
+	for _, n := range u.notes {
+		if n.PRNumber != number && !(n.Backport && n.BackportPRNumber == number) {
+			merged = append(merged, n)
+		}
+	}
+	for _, n := range notes {
+		if n.PRDate.Before(u.start) {
+			logger.Debug("skipping PR", "reason", "merged before start")
+			break
+		}
+		merged = append(merged, n)
+	}
+
+	merged, err := finishReleaseNotes(logger, u.opts, merged)
+	if err != nil {
+		return err
+	}
+
+	u.notes = merged
+	u.updated = time.Now().UTC()
+	logger.Info("updated unreleased changes", "notes", len(notes), "total", len(merged))
+	return nil
+}
+
+// pullRequestNotes returns the release notes of a merged PR, resolving its
+// backports and reverts as a full collection does.
+func (u *Unreleased) pullRequestNotes(ctx context.Context, logger hclog.Logger, number int) ([]ReleaseNote, error) {
+	id, err := pullRequestNodeID(ctx, u.client, u.opts.Owner, u.opts.Repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving PR %d: %w", number, err)
+	}
+
+	prs, err := fetchPullRequests(ctx, u.client, logger, u.opts.Cache, []string{id}, u.opts.needsFiles())
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving PR %d: %w", number, err)
+	}
+	prs = dropNoNoteLabels(logger, u.opts, prs)
+
+	var backports map[int]backport
+	if u.opts.Backports {
+		prs, backports, err = resolveBackports(ctx, u.client, logger, u.opts, nil, prs)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving backports: %w", err)
+		}
+	}
+
+	reverts, err := resolveReverts(ctx, u.client, logger, u.opts, prs)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving reverts: %w", err)
+	}
+
+	notes := releaseNotesFromPullRequests(logger, u.opts, prs)
+	applyBackports(notes, backports)
+	applyReverts(notes, u.opts.Owner, u.opts.Repo, reverts)
+	return notes, nil
+}
+
+// Notes returns the current release notes, ordered by the sort keys.
+func (u *Unreleased) Notes() []ReleaseNote {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return append([]ReleaseNote{}, u.notes...)
+}
+
+// Render renders the current release notes as a changelog.
+func (u *Unreleased) Render() (string, error) {
+	u.mu.RLock()
+	notes := append([]ReleaseNote{}, u.notes...)
+	updated := u.updated
+	u.mu.RUnlock()
+
+	next, err := nextVersion(u.opts, u.opts.CurrentVersion, notes)
+	if err != nil {
+		return "", err
+	}
+
+	data := changelogData(u.opts, u.start, updated, notes)
+	data.NextVersion = next
+	if data.Version == "" {
+		data.Version = next
+	}
+
+	return RenderChangelog(u.opts.ChangelogTemplate, u.opts.ReleaseNoteTemplate, data)
+}
+
+// firstInSlice returns the first value that is in haystack.
+func firstInSlice(haystack, values []string) (string, bool) {
+	for _, v := range values {
+		if stringInSlice(haystack, v) {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package changelog
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnreleased(t *testing.T) {
+	api := &fakeGraphQL{prs: map[string]map[string]interface{}{}}
+	api.addPR(1, "first", day(2))
+	api.addPR(2, "second", day(3))
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+	ctx := context.Background()
+	u := NewUnreleased(client, hclog.NewNullLogger(), Options{
+		Owner:        "foo",
+		Repo:         "bar",
+		Branch:       "main",
+		NoNoteLabels: []string{"no-release-note"},
+		Backports:    true,
+	}, day(1))
+
+	assert.Empty(t, u.Notes())
+	assert.NoError(t, u.Refresh(ctx))
+	assert.Equal(t, []string{"second", "first"}, noteTexts(u.Notes()))
+
+	// an edited PR replaces its notes
+	api.prs["pr2"]["title"] = "second, edited"
+	assert.NoError(t, u.UpdatePullRequest(ctx, 2, nil))
+	assert.Equal(t, []string{"second, edited", "first"}, noteTexts(u.Notes()))
+
+	// a revert drops the reverted PR along with itself
+	api.addPR(3, "Revert first", day(4))
+	api.prs["pr3"]["body"] = "Reverts foo/bar#1"
+	assert.NoError(t, u.UpdatePullRequest(ctx, 3, nil))
+	assert.Equal(t, []string{"second, edited"}, noteTexts(u.Notes()))
+
+	// a backport is replaced by its original, once however often it changes
+	api.bases = map[string]string{"pr4": "develop"}
+	api.addPR(4, "fourth", day(5))
+	api.addPR(5, "Backport of #4", day(6))
+	api.prs["pr5"]["body"] = "Backport of #4"
+	assert.NoError(t, u.UpdatePullRequest(ctx, 5, nil))
+	assert.NoError(t, u.UpdatePullRequest(ctx, 5, nil))
+	notes := u.Notes()
+	if assert.Len(t, notes, 2) {
+		assert.Equal(t, "fourth", notes[0].Text)
+		assert.True(t, notes[0].Backport)
+		assert.Equal(t, 5, notes[0].BackportPRNumber)
+	}
+
+	// a no note label removes the notes
+	assert.NoError(t, u.UpdatePullRequest(ctx, 2, []string{"no-release-note"}))
+	assert.Equal(t, []string{"fourth"}, noteTexts(u.Notes()))
+
+	assert.Error(t, u.UpdatePullRequest(ctx, 10, nil))
+}
@@ -0,0 +1,154 @@
+package changelog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxWebhookBody limits the size of webhook payloads that are read.
+const maxWebhookBody = 25 << 20
+
+// VerifyWebhookSignature checks the X-Hub-Signature-256 header of a GitHub
+// webhook delivery against the HMAC of its body.
+func VerifyWebhookSignature(secret, body []byte, signature string) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return errors.New("missing sha256 signature")
+	}
+	actual, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(actual, mac.Sum(nil)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// pullRequestEvent is the subset of a pull_request webhook payload that is
+// needed to track unreleased changes.
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int  `json:"number"`
+		Merged bool `json:"merged"`
+		Base   struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	} `json:"pull_request"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// affectsUnreleased returns true if the event may change the release notes
+// of a PR merged into the branch of opts.
+func (e pullRequestEvent) affectsUnreleased(opts Options) bool {
+	if !e.PullRequest.Merged || e.PullRequest.Base.Ref != opts.Branch {
+		return false
+	}
+	if !strings.EqualFold(e.Repository.Owner.Login, opts.Owner) || !strings.EqualFold(e.Repository.Name, opts.Repo) {
+		return false
+	}
+	switch e.Action {
+	case "closed", "edited", "labeled", "unlabeled":
+		return true
+	}
+	return false
+}
+
+// NewWebhookHandler returns an HTTP handler that updates the unreleased
+// changes from GitHub pull_request webhooks delivered to /webhook and serves
+// the current changelog at /changelog.md and the release notes at
+// /changelog.json.
+func NewWebhookHandler(u *Unreleased, secret []byte) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+		handleWebhook(u, secret, w, r)
+	})
+	mux.HandleFunc("/changelog.md", func(w http.ResponseWriter, r *http.Request) {
+		cl, err := u.Render()
+		if err != nil {
+			u.logger.Error("error rendering changelog", "err", err)
+			http.Error(w, "error rendering changelog", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(cl))
+	})
+	mux.HandleFunc("/changelog.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(u.Notes()); err != nil {
+			u.logger.Error("error writing release notes", "err", err)
+		}
+	})
+	return mux
+}
+
+func handleWebhook(u *Unreleased, secret []byte, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	logger := u.logger.With("delivery", r.Header.Get("X-GitHub-Delivery"))
+
+	if err := VerifyWebhookSignature(secret, body, r.Header.Get("X-Hub-Signature-256")); err != nil {
+		logger.Warn("rejected webhook", "err", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		logger.Debug("ignoring webhook", "event", r.Header.Get("X-GitHub-Event"))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var event pullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if !event.affectsUnreleased(u.opts) {
+		logger.Debug("ignoring webhook", "event", "pull_request", "action", event.Action, "pr", event.PullRequest.Number)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	labels := make([]string, 0, len(event.PullRequest.Labels))
+	for _, l := range event.PullRequest.Labels {
+		labels = append(labels, l.Name)
+	}
+
+	if err := u.UpdatePullRequest(r.Context(), event.PullRequest.Number, labels); err != nil {
+		logger.Error("error updating unreleased changes", "pr", event.PullRequest.Number, "err", err)
+		http.Error(w, "error updating unreleased changes", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
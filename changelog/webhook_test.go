@@ -0,0 +1,103 @@
+package changelog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSignature(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	const body = `{"action":"closed"}`
+
+	assert.NoError(t, VerifyWebhookSignature([]byte("secret"), []byte(body), testSignature("secret", body)))
+	assert.Error(t, VerifyWebhookSignature([]byte("secret"), []byte(body), testSignature("other", body)))
+	assert.Error(t, VerifyWebhookSignature([]byte("secret"), []byte(body), "sha256=zz"))
+	assert.Error(t, VerifyWebhookSignature([]byte("secret"), []byte(body), ""))
+}
+
+func TestPullRequestEvent_affectsUnreleased(t *testing.T) {
+	opts := Options{Owner: "foo", Repo: "bar", Branch: "main"}
+
+	for _, c := range []struct {
+		expected bool
+		payload  string
+	}{
+		{true, `{"action":"closed","pull_request":{"merged":true,"base":{"ref":"main"}},"repository":{"name":"Bar","owner":{"login":"foo"}}}`},
+		{true, `{"action":"labeled","pull_request":{"merged":true,"base":{"ref":"main"}},"repository":{"name":"bar","owner":{"login":"foo"}}}`},
+		{false, `{"action":"closed","pull_request":{"merged":false,"base":{"ref":"main"}},"repository":{"name":"bar","owner":{"login":"foo"}}}`},
+		{false, `{"action":"closed","pull_request":{"merged":true,"base":{"ref":"release"}},"repository":{"name":"bar","owner":{"login":"foo"}}}`},
+		{false, `{"action":"closed","pull_request":{"merged":true,"base":{"ref":"main"}},"repository":{"name":"baz","owner":{"login":"foo"}}}`},
+		{false, `{"action":"synchronize","pull_request":{"merged":true,"base":{"ref":"main"}},"repository":{"name":"bar","owner":{"login":"foo"}}}`},
+	} {
+		t.Run(c.payload, func(t *testing.T) {
+			var event pullRequestEvent
+			assert.NoError(t, json.Unmarshal([]byte(c.payload), &event))
+			assert.Equal(t, c.expected, event.affectsUnreleased(opts))
+		})
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	u := NewUnreleased(nil, hclog.NewNullLogger(), Options{
+		Owner:               "foo",
+		Repo:                "bar",
+		Branch:              "main",
+		ChangelogTemplate:   `{{range .Notes}}{{renderReleaseNote .}}{{end}}`,
+		ReleaseNoteTemplate: `{{.Text}} (#{{.PRNumber}})`,
+	}, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	u.notes = []ReleaseNote{{Text: "foo", PRNumber: 1}}
+
+	server := httptest.NewServer(NewWebhookHandler(u, []byte("secret")))
+	defer server.Close()
+
+	post := func(event, body, signature string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/webhook", strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-Hub-Signature-256", signature)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	const unmerged = `{"action":"closed","pull_request":{"number":2,"merged":false,"base":{"ref":"main"}},"repository":{"name":"bar","owner":{"login":"foo"}}}`
+	assert.Equal(t, http.StatusUnauthorized, post("pull_request", unmerged, testSignature("wrong", unmerged)))
+	assert.Equal(t, http.StatusNoContent, post("pull_request", unmerged, testSignature("secret", unmerged)))
+	assert.Equal(t, http.StatusNoContent, post("ping", `{}`, testSignature("secret", `{}`)))
+
+	// a no note label removes the notes of the PR without querying GitHub
+	const labeled = `{"action":"labeled","pull_request":{"number":1,"merged":true,"base":{"ref":"main"},"labels":[{"name":"no-release-note"}]},"repository":{"name":"bar","owner":{"login":"foo"}}}`
+	u.opts.NoNoteLabels = []string{"no-release-note"}
+	resp, err := http.Get(server.URL + "/changelog.md")
+	assert.NoError(t, err)
+	md, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "foo (#1)", string(md))
+
+	assert.Equal(t, http.StatusNoContent, post("pull_request", labeled, testSignature("secret", labeled)))
+
+	resp, err = http.Get(server.URL + "/changelog.json")
+	assert.NoError(t, err)
+	var notes []ReleaseNote
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&notes))
+	resp.Body.Close()
+	assert.Empty(t, notes)
+}
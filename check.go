@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+// errCheckFailed is returned by the check command when diagnostics were
+// reported for the release notes.
+var errCheckFailed = errors.New("release notes have problems")
+
+func loadBody(filename string) (string, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// checkCommand lints the release notes of a single PR, printing any
+// diagnostics to stdout.
+type checkCommand struct {
+	gh           githubFlags
+	bodyFile     string
+	allowedTypes stringSliceFlag
+}
+
+func (c *checkCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	flagset.StringVar(&c.bodyFile,
+		"body-file",
+		"",
+		"Path to a file containing the PR body to check instead of fetching a PR (use - for stdin)",
+	)
+	flagset.Var(&c.allowedTypes,
+		"allowed-type",
+		"Allowed release note type (can be set multiple times, leave unset to allow any type)",
+	)
+}
+
+func (c *checkCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	var body string
+	if c.bodyFile != "" {
+		if len(args) != 0 {
+			return errors.New("no arguments are allowed with -body-file")
+		}
+		var err error
+		body, err = loadBody(c.bodyFile)
+		if err != nil {
+			return fmt.Errorf("error reading body: %w", err)
+		}
+	} else {
+		if err := c.gh.validate(); err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errors.New("a PR number is required")
+		}
+		number, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid PR number %q", args[0])
+		}
+
+		client, logUsage := c.gh.client(ctx, logger)
+		defer logUsage()
+
+		_, body, err = changelog.PullRequestBody(ctx, client, c.gh.owner, c.gh.repo, number)
+		if err != nil {
+			return fmt.Errorf("error retrieving PR %d: %w", number, err)
+		}
+	}
+
+	diags := changelog.LintReleaseNotes(body, c.allowedTypes)
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+
+	if len(diags) > 0 {
+		return errCheckFailed
+	}
+	return nil
+}
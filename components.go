@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+// componentsConfig is the JSON configuration of the components command.
+type componentsConfig struct {
+	Components []componentConfig `json:"components"`
+}
+
+type componentConfig struct {
+	// Name of the component, used to label output.
+	Name string `json:"name"`
+
+	// Paths and ExcludePaths are globs of the files the component consists
+	// of, see the -include-path and -exclude-path flags.
+	Paths        []string `json:"paths"`
+	ExcludePaths []string `json:"exclude_paths"`
+
+	// TagPrefix is prepended to the start and end arguments to find the tags
+	// of the component, for example "api/" for tags like "api/v1.2.0".
+	TagPrefix string `json:"tag_prefix"`
+
+	// Changelog and ReleaseNote are template paths relative to the config
+	// file, the command's templates are used if not set.
+	Changelog   string `json:"changelog"`
+	ReleaseNote string `json:"releasenote"`
+
+	// Output is a path relative to the config file to write the changelog
+	// to, if not set it is written to stdout.
+	Output string `json:"output"`
+}
+
+func loadComponentsConfig(filename string) (*componentsConfig, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config componentsConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Components) == 0 {
+		return nil, errors.New("no components are configured")
+	}
+	for i, c := range config.Components {
+		if c.Name == "" {
+			return nil, fmt.Errorf("component %d has no name", i)
+		}
+	}
+
+	return &config, nil
+}
+
+type componentsCommand struct {
+	gh        githubFlags
+	notes     noteFlags
+	versions  versionFlags
+	cache     cacheFlags
+	templates templateFlags
+	config    string
+}
+
+func (c *componentsCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.versions.register(flagset)
+	c.cache.register(flagset)
+	c.templates.register(flagset, true)
+	flagset.StringVar(&c.config,
+		"config",
+		"",
+		"Path to the JSON components configuration (required)",
+	)
+}
+
+func (c *componentsCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+	if c.config == "" {
+		return errors.New("-config is required")
+	}
+	if len(args) != 2 {
+		return errors.New("2 arguments are required")
+	}
+
+	config, err := loadComponentsConfig(c.config)
+	if err != nil {
+		return fmt.Errorf("error loading components config: %w", err)
+	}
+	configDir := filepath.Dir(c.config)
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.ChangelogTemplate, opts.ReleaseNoteTemplate, err = c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	client, logUsage := c.gh.client(ctx, logger)
+	defer logUsage()
+
+	times := map[string]time.Time{}
+	resolve := func(prefix, v string) (time.Time, error) {
+		key := prefix + "\x00" + v
+		if t, ok := times[key]; ok {
+			return t, nil
+		}
+
+		var t time.Time
+		var err error
+		if prefix == "" {
+			t, err = resolveTime(ctx, client, c.gh.owner, c.gh.repo, v)
+		} else {
+			t, err = changelog.TimeFromTag(ctx, client, c.gh.owner, c.gh.repo, prefix+v)
+			if err != nil {
+				err = fmt.Errorf("error looking up tag %s: %w", prefix+v, err)
+			}
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		times[key] = t
+		return t, nil
+	}
+
+	components := make([]changelog.Component, 0, len(config.Components))
+	for _, cc := range config.Components {
+		component, err := cc.component(configDir, args, resolve)
+		if err != nil {
+			return fmt.Errorf("component %s: %w", cc.Name, err)
+		}
+		components = append(components, component)
+	}
+
+	changelogs, err := changelog.BuildComponentChangelogs(ctx, client, logger, opts, components)
+	if err != nil {
+		return err
+	}
+
+	for i, cl := range changelogs {
+		output := config.Components[i].Output
+		if output == "" {
+			fmt.Printf("# %s\n\n%s\n", cl.Name, cl.Changelog)
+			continue
+		}
+
+		output = filepath.Join(configDir, output)
+		if err := ioutil.WriteFile(output, []byte(cl.Changelog), 0644); err != nil {
+			return fmt.Errorf("error writing changelog for component %s: %w", cl.Name, err)
+		}
+		logger.Info("wrote component changelog", "component", cl.Name, "path", output)
+	}
+
+	return nil
+}
+
+func (cc componentConfig) component(
+	configDir string,
+	args []string,
+	resolve func(prefix, v string) (time.Time, error),
+) (changelog.Component, error) {
+	start, err := resolve(cc.TagPrefix, args[0])
+	if err != nil {
+		return changelog.Component{}, err
+	}
+
+	end, err := resolve(cc.TagPrefix, args[1])
+	if err != nil {
+		return changelog.Component{}, err
+	}
+
+	changelogTemplate, err := loadTemplate(relativePath(configDir, cc.Changelog))
+	if err != nil {
+		return changelog.Component{}, fmt.Errorf("error loading changelog template: %w", err)
+	}
+
+	releaseNoteTemplate, err := loadTemplate(relativePath(configDir, cc.ReleaseNote))
+	if err != nil {
+		return changelog.Component{}, fmt.Errorf("error loading release note template: %w", err)
+	}
+
+	startRef, endRef := commitRef(args[0]), commitRef(args[1])
+
+	// the tags of a component are its current and released versions
+	var currentVersion, version string
+	if cc.TagPrefix != "" {
+		startRef, endRef = cc.TagPrefix+args[0], cc.TagPrefix+args[1]
+		if changelog.ValidVersion(args[0]) {
+			currentVersion = args[0]
+		}
+		if changelog.ValidVersion(args[1]) {
+			version = args[1]
+		}
+	}
+
+	return changelog.Component{
+		Name: cc.Name,
+		Paths: changelog.PathFilter{
+			Include: cc.Paths,
+			Exclude: cc.ExcludePaths,
+		},
+		Start:    start,
+		End:      end,
+		StartRef: startRef,
+		EndRef:   endRef,
+
+		CurrentVersion: currentVersion,
+		Version:        version,
+
+		ChangelogTemplate:   changelogTemplate,
+		ReleaseNoteTemplate: releaseNoteTemplate,
+	}, nil
+}
+
+// relativePath resolves a path relative to dir, leaving empty and absolute
+// paths unchanged.
+func relativePath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+// logFlags are the flags that control logging, they are available on every
+// command.
+type logFlags struct {
+	level  string
+	format string
+	quiet  bool
+}
+
+func (f *logFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.level,
+		"log-level",
+		envString("CHANGELOG_GEN_LOG_LEVEL", "info"),
+		"Log level, one of trace, debug, info, warn or error",
+	)
+	flagset.StringVar(&f.format,
+		"log-format",
+		envString("CHANGELOG_GEN_LOG_FORMAT", "text"),
+		"Log format, either text or json",
+	)
+	flagset.BoolVar(&f.quiet,
+		"quiet",
+		envBool("CHANGELOG_GEN_QUIET", false),
+		"Only log errors, overrides -log-level",
+	)
+}
+
+// logger returns a logger writing to stderr configured by the flags.
+func (f *logFlags) logger() (hclog.Logger, error) {
+	level := hclog.LevelFromString(f.level)
+	if level == hclog.NoLevel {
+		return nil, fmt.Errorf("invalid log level %q", f.level)
+	}
+	if f.quiet {
+		level = hclog.Error
+	}
+
+	var json bool
+	switch f.format {
+	case "text":
+	case "json":
+		json = true
+	default:
+		return nil, fmt.Errorf("invalid log format %q", f.format)
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Level:      level,
+		JSONFormat: json,
+		Output:     os.Stderr,
+	}), nil
+}
+
+// githubFlags are the flags needed to query a GitHub repository.
+type githubFlags struct {
+	token string
+	owner string
+	repo  string
+}
+
+func (f *githubFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.token,
+		"github-token",
+		envString("GITHUB_TOKEN", ""),
+		"A personal GitHub access token (required)",
+	)
+	flagset.StringVar(&f.owner,
+		"owner",
+		envString("GITHUB_OWNER", ""),
+		"GitHub repository owner",
+	)
+	flagset.StringVar(&f.repo,
+		"repo",
+		envString("GITHUB_REPO", ""),
+		"Github repository name",
+	)
+}
+
+func (f *githubFlags) validate() error {
+	if f.token == "" {
+		return errors.New("GitHub token must be set via -github-token or $GITHUB_TOKEN")
+	}
+
+	if f.owner == "" {
+		return errors.New("GitHub repository owner must be set via -owner or $GITHUB_OWNER")
+	}
+
+	if f.repo == "" {
+		return errors.New("GitHub repository must be set via -repo or $GITHUB_REPO")
+	}
+
+	return nil
+}
+
+// client returns a GitHub client and a function to log its rate limit usage
+// once the command is finished with it.
+func (f *githubFlags) client(ctx context.Context, logger hclog.Logger) (*githubv4.Client, func()) {
+	client, transport := githubClient(ctx, logger, f.token)
+	return client, transport.LogUsage
+}
+
+// restFlags are the flags for commands that also use the GitHub REST API.
+type restFlags struct {
+	apiURL string
+}
+
+func (f *restFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.apiURL,
+		"api-url",
+		envString("GITHUB_API_URL", changelog.DefaultAPIURL),
+		"GitHub REST API URL",
+	)
+}
+
+// clients returns GitHub GraphQL and REST clients sharing a rate limit
+// tracking transport, and a function to log its usage once the command is
+// finished with them.
+func (f *restFlags) clients(ctx context.Context, logger hclog.Logger, gh *githubFlags) (*githubv4.Client, *changelog.RESTClient, func(), error) {
+	httpClient, transport := githubHTTPClient(ctx, logger, gh.token)
+	restClient, err := changelog.NewRESTClient(httpClient, f.apiURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return githubv4.NewClient(httpClient), restClient, transport.LogUsage, nil
+}
+
+// noteFlags are the flags that control which PRs and release notes are
+// included and how they are typed.
+type noteFlags struct {
+	branch       string
+	noNoteLabels stringSliceFlag
+	includePaths stringSliceFlag
+	excludePaths stringSliceFlag
+	typeLabels   stringSliceFlag
+	allowedTypes stringSliceFlag
+	strict       bool
+	firstTime    bool
+	backports    bool
+	previous     string
+	sortKeys     stringSliceFlag
+	authors      authorFlags
+}
+
+func (f *noteFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.branch,
+		"branch",
+		envString("GITHUB_BRANCH", "master"),
+		"Github branch (defaults to master)",
+	)
+	flagset.Var(&f.noNoteLabels,
+		"no-note-label",
+		"Label to indicate a PR should not generate a release note (can be set multiple times to match multiple labels)",
+	)
+	flagset.Var(&f.includePaths,
+		"include-path",
+		"Only include PRs that change files matching this glob, ** matches any number of directories (can be set multiple times)",
+	)
+	flagset.Var(&f.excludePaths,
+		"exclude-path",
+		"Ignore changed files matching this glob when filtering PRs by path (can be set multiple times)",
+	)
+	flagset.Var(&f.typeLabels,
+		"type-label",
+		"Label to release note type mapping in the form label=type, used for notes without a type in their block (can be set multiple times, earlier mappings take precedence)",
+	)
+	flagset.Var(&f.allowedTypes,
+		"allowed-type",
+		"Allowed release note type, notes with other types are logged (can be set multiple times)",
+	)
+	flagset.BoolVar(&f.strict,
+		"strict",
+		false,
+		"Fail if any release note has a type not set via -allowed-type",
+	)
+	flagset.BoolVar(&f.firstTime,
+		"first-time-contributors",
+		false,
+		"Check whether each contributor had PRs merged before the range (requires a GitHub search per contributor)",
+	)
+	flagset.BoolVar(&f.backports,
+		"backports",
+		false,
+		"Replace backport PRs with the PRs they backport, and include PRs whose commits were cherry-picked onto the branch with git cherry-pick -x",
+	)
+	flagset.StringVar(&f.previous,
+		"previous-changelog",
+		"",
+		"Path to an existing markdown changelog, notes already listed in its released sections are excluded",
+	)
+	flagset.Var(&f.sortKeys,
+		"sort",
+		"Sort notes by date, number, scope:<label prefix>, text or block, prefixed with - for descending order (can be set multiple times, defaults to -date)",
+	)
+	f.authors.register(flagset)
+}
+
+// options returns the changelog options for the flags, without templates.
+func (f *noteFlags) options(gh *githubFlags) (changelog.Options, error) {
+	if f.strict && len(f.allowedTypes) < 1 {
+		return changelog.Options{}, errors.New("-strict requires at least one -allowed-type")
+	}
+
+	branch := f.branch
+	if branch == "" {
+		branch = "master"
+	}
+
+	noNoteLabels := []string(f.noNoteLabels)
+	if len(noNoteLabels) < 1 {
+		noNoteLabels = []string{"no-release-note", "release-note-none"}
+	}
+
+	typeLabels, err := parseTypeLabels(f.typeLabels)
+	if err != nil {
+		return changelog.Options{}, err
+	}
+
+	var sortKeys []changelog.SortKey
+	for _, v := range f.sortKeys {
+		k, err := changelog.ParseSortKey(v)
+		if err != nil {
+			return changelog.Options{}, err
+		}
+		sortKeys = append(sortKeys, k)
+	}
+
+	var previous string
+	if f.previous != "" {
+		b, err := ioutil.ReadFile(f.previous)
+		if err != nil {
+			return changelog.Options{}, fmt.Errorf("error reading previous changelog: %w", err)
+		}
+		previous = string(b)
+	}
+
+	return changelog.Options{
+		Owner:  gh.owner,
+		Repo:   gh.repo,
+		Branch: branch,
+
+		NoNoteLabels: noNoteLabels,
+		Paths: changelog.PathFilter{
+			Include: []string(f.includePaths),
+			Exclude: []string(f.excludePaths),
+		},
+		TypeLabels:   typeLabels,
+		AllowedTypes: []string(f.allowedTypes),
+		Strict:       f.strict,
+		Backports:    f.backports,
+
+		SortKeys:          sortKeys,
+		PreviousChangelog: previous,
+
+		AuthorPrefixes: []string(f.authors.prefixes),
+		BotLogins:      []string(f.authors.botLogins),
+		ExcludeBots:    f.authors.excludeBots,
+
+		FirstTimeContributors: f.firstTime,
+	}, nil
+}
+
+// authorFlags are the flags that control how the author of a PR is
+// determined.
+type authorFlags struct {
+	prefixes    stringSliceFlag
+	botLogins   stringSliceFlag
+	excludeBots bool
+}
+
+func (f *authorFlags) register(flagset *flag.FlagSet) {
+	flagset.Var(&f.prefixes,
+		"author-prefix",
+		"Additional line prefix in a PR body, like Reported-by:, that overrides the PR author when followed by @login (can be set multiple times)",
+	)
+	flagset.Var(&f.botLogins,
+		"bot-login",
+		"Login of a bot account whose PRs are credited to the first non-bot commit author, GitHub Apps and [bot] logins are always bots (can be set multiple times)",
+	)
+	flagset.BoolVar(&f.excludeBots,
+		"exclude-bots",
+		false,
+		"Skip all PRs opened by bots instead of crediting them to the first non-bot commit author",
+	)
+}
+
+func parseTypeLabels(values []string) ([]changelog.TypeLabel, error) {
+	typeLabels := make([]changelog.TypeLabel, 0, len(values))
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid type label %q, expected label=type", v)
+		}
+		if parts[1] == "" && !strings.HasSuffix(parts[0], "*") {
+			return nil, fmt.Errorf("invalid type label %q, type can only be empty for prefix labels", v)
+		}
+		typeLabels = append(typeLabels, changelog.TypeLabel{
+			Label: parts[0],
+			Type:  parts[1],
+		})
+	}
+	return typeLabels, nil
+}
+
+// versionFlags are the flags for suggesting the next version.
+type versionFlags struct {
+	version    string
+	current    string
+	prerelease string
+	bumpTypes  stringSliceFlag
+}
+
+func (f *versionFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.version,
+		"version",
+		"",
+		"Version of the release, available to templates (defaults to the suggested next version)",
+	)
+	flagset.StringVar(&f.current,
+		"current-version",
+		"",
+		"Semantic version of the release at the start of the range, used to suggest the next version",
+	)
+	flagset.StringVar(&f.prerelease,
+		"prerelease",
+		"",
+		"Pre-release identifier of the next version, like beta or rc",
+	)
+	flagset.Var(&f.bumpTypes,
+		"bump-type",
+		"Release note type to version bump mapping in the form type=major|minor|patch (can be set multiple times, earlier mappings take precedence)",
+	)
+}
+
+// apply sets the version options of opts from the flags.
+func (f *versionFlags) apply(opts *changelog.Options) error {
+	if f.current != "" && !changelog.ValidVersion(f.current) {
+		return fmt.Errorf("-current-version %q is not a semantic version", f.current)
+	}
+
+	rules := make([]changelog.BumpRule, 0, len(f.bumpTypes))
+	for _, v := range f.bumpTypes {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid bump type %q, expected type=bump", v)
+		}
+		bump, err := changelog.ParseBump(parts[1])
+		if err != nil {
+			return err
+		}
+		rules = append(rules, changelog.BumpRule{
+			Type: parts[0],
+			Bump: bump,
+		})
+	}
+
+	opts.Version = f.version
+	opts.CurrentVersion = f.current
+	opts.Prerelease = f.prerelease
+	opts.BumpRules = rules
+	return nil
+}
+
+// cacheFlags are the flags for the on-disk response cache.
+type cacheFlags struct {
+	dir     string
+	noCache bool
+	clear   bool
+}
+
+func (f *cacheFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.dir,
+		"cache-dir",
+		envString("CHANGELOG_GEN_CACHE_DIR", ""),
+		"Directory to cache GitHub responses in across runs (leave blank to disable caching)",
+	)
+	flagset.BoolVar(&f.noCache,
+		"no-cache",
+		false,
+		"Bypass the cache, neither reading from nor writing to it",
+	)
+	flagset.BoolVar(&f.clear,
+		"clear-cache",
+		false,
+		"Clear the cache before running",
+	)
+}
+
+// cache returns the configured cache, clearing it first if requested, or nil
+// if caching is disabled.
+func (f *cacheFlags) cache() (*changelog.Cache, error) {
+	if f.dir == "" {
+		if f.clear {
+			return nil, errors.New("-clear-cache requires -cache-dir or $CHANGELOG_GEN_CACHE_DIR")
+		}
+		return nil, nil
+	}
+
+	cache := changelog.NewCache(f.dir)
+	if f.clear {
+		if err := cache.Clear(); err != nil {
+			return nil, fmt.Errorf("error clearing cache: %w", err)
+		}
+	}
+	if f.noCache {
+		return nil, nil
+	}
+
+	return cache, nil
+}
+
+// stateFlags are the flags for incremental runs.
+type stateFlags struct {
+	file string
+}
+
+func (f *stateFlags) register(flagset *flag.FlagSet) {
+	flagset.StringVar(&f.file,
+		"state-file",
+		envString("CHANGELOG_GEN_STATE_FILE", ""),
+		"Path to a state file of already processed PRs, if set only history after the last run is scanned and the file is updated",
+	)
+}
+
+// load returns the state from the state file, or nil if it is not set.
+func (f *stateFlags) load() (*changelog.State, error) {
+	if f.file == "" {
+		return nil, nil
+	}
+	state, err := changelog.LoadState(f.file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading state: %w", err)
+	}
+	return state, nil
+}
+
+// save writes the state to the state file, if it is set.
+func (f *stateFlags) save(state *changelog.State) error {
+	if state == nil {
+		return nil
+	}
+	if err := state.Save(f.file); err != nil {
+		return fmt.Errorf("error saving state: %w", err)
+	}
+	return nil
+}
+
+// templateFlags are the flags for the changelog and release note templates.
+type templateFlags struct {
+	changelog   string
+	releaseNote string
+}
+
+func (f *templateFlags) register(flagset *flag.FlagSet, includeChangelog bool) {
+	if includeChangelog {
+		flagset.StringVar(&f.changelog,
+			"changelog",
+			"",
+			"Changelog template path (leave blank for built-in template)",
+		)
+	}
+	flagset.StringVar(&f.releaseNote,
+		"releasenote",
+		"",
+		"Release note template path (leave blank for built-in template)",
+	)
+}
+
+// load returns the changelog and release note template text, empty strings
+// indicate the built-in templates should be used.
+func (f *templateFlags) load() (string, string, error) {
+	changelogTemplate, err := loadTemplate(f.changelog)
+	if err != nil {
+		return "", "", fmt.Errorf("error loading changelog template: %w", err)
+	}
+
+	releaseNoteTemplate, err := loadTemplate(f.releaseNote)
+	if err != nil {
+		return "", "", fmt.Errorf("error loading release note template: %w", err)
+	}
+
+	return changelogTemplate, releaseNoteTemplate, nil
+}
+
+func loadTemplate(filename string) (string, error) {
+	if filename == "" {
+		return "", nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+var commitRE = regexp.MustCompile("^[0-9a-f]{5,40}$")
+
+func parseCommitOrTime(v string) (string, time.Time, error) {
+	if commitRE.MatchString(v) {
+		return v, time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return "", t, nil
+}
+
+// resolveTime parses a commit or RFC3339 timestamp argument, looking up the
+// commit time if necessary.
+func resolveTime(ctx context.Context, client *githubv4.Client, owner, repo, v string) (time.Time, error) {
+	commit, t, err := parseCommitOrTime(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a commit SHA or RFC3339 timestamp", v)
+	}
+	if commit == "" {
+		return t, nil
+	}
+	t, err = changelog.TimeFromCommit(ctx, client, owner, repo, commit)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error looking up commit %s: %w", commit, err)
+	}
+	return t, nil
+}
+
+// commitRef returns v if it is a commit SHA rather than a timestamp.
+func commitRef(v string) string {
+	commit, _, _ := parseCommitOrTime(v)
+	return commit
+}
+
+// rangeArgs validates the start and end arguments and resolves them to times.
+func rangeArgs(ctx context.Context, client *githubv4.Client, owner, repo string, args []string) (time.Time, time.Time, error) {
+	if len(args) != 2 {
+		return time.Time{}, time.Time{}, errors.New("2 arguments are required")
+	}
+
+	start, err := resolveTime(ctx, client, owner, repo, args[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	end, err := resolveTime(ctx, client, owner, repo, args[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return start, end, nil
+}
+
+type generateCommand struct {
+	gh        githubFlags
+	notes     noteFlags
+	versions  versionFlags
+	cache     cacheFlags
+	state     stateFlags
+	templates templateFlags
+}
+
+func (c *generateCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.versions.register(flagset)
+	c.cache.register(flagset)
+	c.state.register(flagset)
+	c.templates.register(flagset, true)
+}
+
+func (c *generateCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.State, err = c.state.load()
+	if err != nil {
+		return err
+	}
+
+	opts.ChangelogTemplate, opts.ReleaseNoteTemplate, err = c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	client, logUsage := c.gh.client(ctx, logger)
+	defer logUsage()
+
+	start, end, err := rangeArgs(ctx, client, c.gh.owner, c.gh.repo, args)
+	if err != nil {
+		return err
+	}
+	opts.StartRef, opts.EndRef = commitRef(args[0]), commitRef(args[1])
+
+	cl, err := changelog.BuildChangelog(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return err
+	}
+
+	if err := c.state.save(opts.State); err != nil {
+		return err
+	}
+
+	fmt.Println(cl)
+	return nil
+}
+
+type exportCommand struct {
+	gh    githubFlags
+	notes noteFlags
+	cache cacheFlags
+	state stateFlags
+}
+
+func (c *exportCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.cache.register(flagset)
+	c.state.register(flagset)
+}
+
+func (c *exportCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.State, err = c.state.load()
+	if err != nil {
+		return err
+	}
+
+	client, logUsage := c.gh.client(ctx, logger)
+	defer logUsage()
+
+	start, end, err := rangeArgs(ctx, client, c.gh.owner, c.gh.repo, args)
+	if err != nil {
+		return err
+	}
+
+	rns, err := changelog.CollectReleaseNotes(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return err
+	}
+
+	if err := c.state.save(opts.State); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rns); err != nil {
+		return fmt.Errorf("error writing release notes: %w", err)
+	}
+	return nil
+}
+
+type nextVersionCommand struct {
+	gh       githubFlags
+	notes    noteFlags
+	versions versionFlags
+	cache    cacheFlags
+	state    stateFlags
+}
+
+func (c *nextVersionCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.versions.register(flagset)
+	c.cache.register(flagset)
+	c.state.register(flagset)
+}
+
+func (c *nextVersionCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.State, err = c.state.load()
+	if err != nil {
+		return err
+	}
+
+	client, logUsage := c.gh.client(ctx, logger)
+	defer logUsage()
+
+	start, end, err := rangeArgs(ctx, client, c.gh.owner, c.gh.repo, args)
+	if err != nil {
+		return err
+	}
+
+	rns, err := changelog.CollectReleaseNotes(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return err
+	}
+
+	if err := c.state.save(opts.State); err != nil {
+		return err
+	}
+
+	bump := changelog.SuggestBump(rns, opts.BumpRules)
+	if opts.CurrentVersion == "" {
+		fmt.Println(bump)
+		return nil
+	}
+
+	next, err := changelog.NextVersion(opts.CurrentVersion, bump, opts.Prerelease)
+	if err != nil {
+		return err
+	}
+	fmt.Println(next)
+	return nil
+}
@@ -2,14 +2,12 @@ package main // import "github.com/paultyng/changelog-gen"
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
-	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/shurcooL/githubv4"
@@ -18,17 +16,107 @@ import (
 	"github.com/paultyng/changelog-gen/changelog"
 )
 
-type options struct {
-	// required
-	githubToken string
-	owner       string
-	repo        string
-
-	// optional
-	branch              string
-	changelogTemplate   string
-	releaseNoteTemplate string
-	noNoteLabels        []string
+// runner is implemented by each command, flags are registered before parsing
+// and the remaining arguments are passed to run.
+type runner interface {
+	register(flagset *flag.FlagSet)
+	run(ctx context.Context, logger hclog.Logger, args []string) error
+}
+
+type command struct {
+	name      string
+	argsUsage string
+	synopsis  string
+	newRunner func() runner
+}
+
+func commands() []command {
+	return []command{
+		{
+			name:      "generate",
+			argsUsage: "<start> <end>",
+			synopsis:  "Generate a changelog for the PRs merged between two commits or RFC3339 timestamps.",
+			newRunner: func() runner { return &generateCommand{} },
+		},
+		{
+			name:      "components",
+			argsUsage: "<start> <end>",
+			synopsis:  "Generate a changelog for each component of a monorepo described by a JSON config, querying GitHub once.",
+			newRunner: func() runner { return &componentsCommand{} },
+		},
+		{
+			name:      "next-version",
+			argsUsage: "<start> <end>",
+			synopsis:  "Suggest the next semantic version from the PRs merged between two commits or RFC3339 timestamps.",
+			newRunner: func() runner { return &nextVersionCommand{} },
+		},
+		{
+			name:      "publish",
+			argsUsage: "<start> <end>",
+			synopsis:  "Create or update the GitHub Release for a tag with the changelog of the PRs merged between two commits or RFC3339 timestamps.",
+			newRunner: func() runner { return &publishCommand{} },
+		},
+		{
+			name:      "serve",
+			argsUsage: "<start>",
+			synopsis:  "Serve the changelog of the PRs merged since a commit or RFC3339 timestamp, kept up to date by pull_request webhooks.",
+			newRunner: func() runner { return &serveCommand{} },
+		},
+		{
+			name:      "check",
+			argsUsage: "<pr number>",
+			synopsis:  "Check the release note blocks of a single PR and report any problems.",
+			newRunner: func() runner { return &checkCommand{} },
+		},
+		{
+			name:      "export",
+			argsUsage: "<start> <end>",
+			synopsis:  "Export the release notes for the PRs merged between two commits or RFC3339 timestamps as JSON.",
+			newRunner: func() runner { return &exportCommand{} },
+		},
+		{
+			name:      "render",
+			argsUsage: "[notes file]",
+			synopsis:  "Render a changelog from release notes previously exported as JSON (reads stdin if no file is given).",
+			newRunner: func() runner { return &renderCommand{} },
+		},
+		{
+			name:      "preview",
+			argsUsage: "<pr number>",
+			synopsis:  "Render the release notes of a single PR using the release note template.",
+			newRunner: func() runner { return &previewCommand{} },
+		},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: changelog-gen <command> [options] [args]\n\nCommands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(out, "  %-13s %s\n", cmd.name, cmd.synopsis)
+	}
+	fmt.Fprintf(out, "\nRun changelog-gen <command> -help for the options of each command.\n")
+}
+
+// newFlagSet returns a flag set for the command with usage output that
+// includes its arguments and synopsis.
+func newFlagSet(cmd command) *flag.FlagSet {
+	flagset := flag.NewFlagSet("changelog-gen "+cmd.name, flag.ExitOnError)
+	flagset.Usage = func() {
+		out := flagset.Output()
+		fmt.Fprintf(out, "Usage: changelog-gen %s [options] %s\n\n%s\n\nOptions:\n", cmd.name, cmd.argsUsage, cmd.synopsis)
+		flagset.PrintDefaults()
+	}
+	return flagset
 }
 
 func envString(key, def string) string {
@@ -38,6 +126,15 @@ func envString(key, def string) string {
 	return def
 }
 
+func envBool(key string, def bool) bool {
+	if env, ok := os.LookupEnv(key); ok {
+		if b, err := strconv.ParseBool(env); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
 type stringSliceFlag []string
 
 func (s *stringSliceFlag) String() string {
@@ -49,190 +146,79 @@ func (s *stringSliceFlag) Set(value string) error {
 	return nil
 }
 
-func parseOptions(args []string) ([]string, *options, error) {
-	flagset := flag.NewFlagSet("changelog-gen", flag.ExitOnError)
-	var flNoNoteLabel stringSliceFlag
-
-	var (
-		flGitHubToken = flagset.String(
-			"github-token",
-			envString("GITHUB_TOKEN", ""),
-			"A personal GitHub access token (required)",
-		)
-
-		flOwner = flagset.String(
-			"owner",
-			envString("GITHUB_OWNER", ""),
-			"GitHub repository owner",
-		)
-
-		flRepo = flagset.String(
-			"repo",
-			envString("GITHUB_REPO", ""),
-			"Github repository name",
-		)
-
-		flBranch = flagset.String(
-			"branch",
-			envString("GITHUB_BRANCH", "master"),
-			"Github branch (defaults to master)",
-		)
-
-		flChangelogTemplate = flagset.String(
-			"changelog",
-			"",
-			"Changelog template path (leave blank for built-in template)",
-		)
-
-		flReleaseNoteTemplate = flagset.String(
-			"releasenote",
-			"",
-			"Release note template path (leave blank for built-in template)",
-		)
-	)
-	flagset.Var(&flNoNoteLabel,
-		"no-note-label",
-		"Label to indicate a PR should not generate a release note (can be set multiple times to match multiple labels)",
-	)
-
-	if err := flagset.Parse(args); err != nil {
-		return nil, nil, err
-	}
-
-	if *flGitHubToken == "" {
-		return nil, nil, errors.New("GitHub token must be set via -github-token or $GITHUB_TOKEN")
-	}
-
-	if *flOwner == "" {
-		return nil, nil, errors.New("GitHub repository owner must be set via -owner or $GITHUB_OWNER")
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(2)
 	}
 
-	if *flRepo == "" {
-		return nil, nil, errors.New("GitHub repository must be set via -repo or $GITHUB_REPO")
+	var cmd command
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage()
+		return
+	default:
+		var ok bool
+		cmd, ok = findCommand(args[0])
+		switch {
+		case ok:
+			args = args[1:]
+		case strings.HasPrefix(args[0], "-") || looksLikeRangeArg(args[0]):
+			// no command given, default to generate for compatibility with
+			// the original flags and arguments
+			cmd, _ = findCommand("generate")
+		default:
+			fmt.Fprintf(flag.CommandLine.Output(), "unknown command %q\n\n", args[0])
+			printUsage()
+			os.Exit(2)
+		}
 	}
 
-	if len(flNoNoteLabel) < 1 {
-		flNoNoteLabel = append(flNoNoteLabel, "no-release-note", "release-note-none")
-	}
+	var lf logFlags
+	r := cmd.newRunner()
+	flagset := newFlagSet(cmd)
+	lf.register(flagset)
+	r.register(flagset)
 
-	return flagset.Args(), &options{
-		githubToken: *flGitHubToken,
-		owner:       *flOwner,
-		repo:        *flRepo,
+	// flag.ExitOnError is used, so parse errors never return
+	_ = flagset.Parse(args)
 
-		branch:              *flBranch,
-		changelogTemplate:   *flChangelogTemplate,
-		releaseNoteTemplate: *flReleaseNoteTemplate,
-		noNoteLabels:        []string(flNoNoteLabel),
-	}, nil
-}
-
-var commitRE = regexp.MustCompile("^[0-9a-f]{5,40}$")
-
-func parseCommitOrTime(v string) (string, time.Time, error) {
-	if commitRE.MatchString(v) {
-		return v, time.Time{}, nil
-	}
-	t, err := time.Parse(time.RFC3339, v)
+	logger, err := lf.logger()
 	if err != nil {
-		return "", time.Time{}, err
-	}
-	return "", t, nil
-}
-
-func loadTemplate(filename string) (string, error) {
-	if filename == "" {
-		return "", nil
+		fmt.Fprintf(flagset.Output(), "%s\n", err)
+		flagset.Usage()
+		os.Exit(2)
 	}
 
-	content, err := ioutil.ReadFile(filename)
+	err = r.run(context.Background(), logger, flagset.Args())
 	if err != nil {
-		return "", err
+		err = fmt.Errorf("changelog-gen %s %s: %w", cmd.name, strings.Join(flagset.Args(), " "), err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
-
-	return string(content), nil
 }
 
-func main() {
-	logger := hclog.New(&hclog.LoggerOptions{
-		// TODO: load level from somewhere else?
-		Level:  hclog.Info,
-		Output: os.Stderr,
-	})
-	err := func() error {
-
-		args, opts, err := parseOptions(os.Args[1:])
-		if err != nil {
-			return err
-		}
-		if len(args) != 2 {
-			return errors.New("2 arguments are required")
-		}
-
-		branch := opts.branch
-		if branch == "" {
-			branch = "master"
-		}
-
-		changelogTemplate, err := loadTemplate(opts.changelogTemplate)
-		if err != nil {
-			return err
-		}
-
-		releaseNoteTemplate, err := loadTemplate(opts.releaseNoteTemplate)
-		if err != nil {
-			return err
-		}
-
-		ctx := context.Background()
-		client := githubClient(ctx, opts.githubToken)
-
-		startCommit, startTime, err := parseCommitOrTime(args[0])
-		if err != nil {
-			return err
-		}
-		if startCommit != "" {
-			startTime, err = changelog.TimeFromCommit(ctx, client, opts.owner, opts.repo, startCommit)
-			if err != nil {
-				return err
-			}
-		}
-
-		endCommit, endTime, err := parseCommitOrTime(args[1])
-		if err != nil {
-			return err
-		}
-		if endCommit != "" {
-			endTime, err = changelog.TimeFromCommit(ctx, client, opts.owner, opts.repo, endCommit)
-			if err != nil {
-				return err
-			}
-		}
-
-		cl, err := changelog.BuildChangelog(
-			ctx, client, logger,
-			changelogTemplate, releaseNoteTemplate,
-			opts.owner, opts.repo, branch,
-			opts.noNoteLabels, startTime, endTime,
-		)
-		if err != nil {
-			panic(err)
-		}
+// looksLikeRangeArg returns true if v is a commit SHA or RFC3339 timestamp,
+// as the start argument of generate is when no command is given.
+func looksLikeRangeArg(v string) bool {
+	_, _, err := parseCommitOrTime(v)
+	return err == nil
+}
 
-		fmt.Println(cl)
-		return nil
-	}()
-	if err != nil {
-		logger.Error("error parsing options", "err", err)
-		os.Exit(1)
-	}
+func githubClient(ctx context.Context, logger hclog.Logger, token string) (*githubv4.Client, *changelog.Transport) {
+	httpClient, transport := githubHTTPClient(ctx, logger, token)
+	return githubv4.NewClient(httpClient), transport
 }
 
-func githubClient(ctx context.Context, token string) *githubv4.Client {
+// githubHTTPClient returns an authenticated HTTP client for the GitHub API
+// and its rate limit tracking transport.
+func githubHTTPClient(ctx context.Context, logger hclog.Logger, token string) (*http.Client, *changelog.Transport) {
+	transport := changelog.NewTransport(nil, logger)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+
 	src := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
-	return client
+	return oauth2.NewClient(ctx, src), transport
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/shurcooL/githubv4"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+// previewCommentMarker identifies the sticky preview comment on a PR.
+const previewCommentMarker = "<!-- changelog-gen:preview -->"
+
+type previewCommand struct {
+	gh           githubFlags
+	rest         restFlags
+	templates    templateFlags
+	typeLabels   stringSliceFlag
+	authors      authorFlags
+	bodyFile     string
+	title        string
+	comment      bool
+	allowedTypes stringSliceFlag
+}
+
+func (c *previewCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.rest.register(flagset)
+	c.templates.register(flagset, false)
+	flagset.Var(&c.typeLabels,
+		"type-label",
+		"Label to release note type mapping in the form label=type, used for notes without a type in their block (can be set multiple times, earlier mappings take precedence)",
+	)
+	c.authors.register(flagset)
+	flagset.StringVar(&c.bodyFile,
+		"body-file",
+		"",
+		"Path to a file containing the PR body to preview instead of fetching a PR (use - for stdin)",
+	)
+	flagset.StringVar(&c.title,
+		"title",
+		"",
+		"PR title to use as the release note when previewing a -body-file without release note blocks",
+	)
+	flagset.BoolVar(&c.comment,
+		"comment",
+		false,
+		"Post the preview and any lint warnings as a single comment on the PR, updating it on later runs",
+	)
+	flagset.Var(&c.allowedTypes,
+		"allowed-type",
+		"Allowed release note type for the lint warnings of -comment (can be set multiple times, leave unset to allow any type)",
+	)
+}
+
+func (c *previewCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	_, releaseNoteTemplate, err := c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	typeLabels, err := parseTypeLabels(c.typeLabels)
+	if err != nil {
+		return err
+	}
+
+	var rns []changelog.ReleaseNote
+	var pr previewPullRequest
+	if c.bodyFile != "" {
+		if c.comment {
+			return errors.New("-comment cannot be used with -body-file")
+		}
+		if len(args) != 0 {
+			return errors.New("no arguments are allowed with -body-file")
+		}
+		body, err := loadBody(c.bodyFile)
+		if err != nil {
+			return fmt.Errorf("error reading body: %w", err)
+		}
+		for _, entry := range changelog.ReleaseNoteBlocks(c.title, body) {
+			rns = append(rns, changelog.ReleaseNote{
+				Type:     entry.Type,
+				Text:     entry.Text,
+				Metadata: entry.Metadata,
+			})
+		}
+	} else {
+		if err := c.gh.validate(); err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return errors.New("a PR number is required")
+		}
+		pr.number, err = strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid PR number %q", args[0])
+		}
+
+		var client *githubv4.Client
+		var logUsage func()
+		if c.comment {
+			client, pr.restClient, logUsage, err = c.rest.clients(ctx, logger, &c.gh)
+			if err != nil {
+				return err
+			}
+		} else {
+			client, logUsage = c.gh.client(ctx, logger)
+		}
+		defer logUsage()
+
+		rns, err = changelog.PullRequestReleaseNotes(ctx, client, logger, c.options(typeLabels), pr.number)
+		if err != nil {
+			return fmt.Errorf("error retrieving PR %d: %w", pr.number, err)
+		}
+
+		if c.comment {
+			// the body is linted for the comment's warnings
+			_, pr.body, err = changelog.PullRequestBody(ctx, client, c.gh.owner, c.gh.repo, pr.number)
+			if err != nil {
+				return fmt.Errorf("error retrieving PR %d: %w", pr.number, err)
+			}
+		}
+	}
+
+	rendered := make([]string, 0, len(rns))
+	for _, rn := range rns {
+		// notes of the none type have no text and are not in the changelog
+		if strings.TrimSpace(rn.Text) == "" {
+			continue
+		}
+		text, err := changelog.RenderReleaseNote(releaseNoteTemplate, rn)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, text)
+	}
+
+	if c.comment {
+		return c.postComment(ctx, logger, pr, rendered)
+	}
+
+	for _, text := range rendered {
+		fmt.Println(text)
+	}
+	return nil
+}
+
+func (c *previewCommand) options(typeLabels []changelog.TypeLabel) changelog.Options {
+	return changelog.Options{
+		Owner:          c.gh.owner,
+		Repo:           c.gh.repo,
+		TypeLabels:     typeLabels,
+		AuthorPrefixes: []string(c.authors.prefixes),
+		BotLogins:      []string(c.authors.botLogins),
+		ExcludeBots:    c.authors.excludeBots,
+	}
+}
+
+// previewPullRequest is the PR being previewed, with the client to comment
+// on it when -comment is set.
+type previewPullRequest struct {
+	number     int
+	body       string
+	restClient *changelog.RESTClient
+}
+
+// postComment posts the rendered release notes of the PR, along with any lint
+// warnings, as a sticky comment on the PR.
+func (c *previewCommand) postComment(ctx context.Context, logger hclog.Logger, pr previewPullRequest, rendered []string) error {
+	diags := changelog.LintReleaseNotes(pr.body, c.allowedTypes)
+
+	comment, err := changelog.UpsertComment(ctx, pr.restClient, logger, c.gh.owner, c.gh.repo, pr.number, previewCommentMarker, previewComment(rendered, diags))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(comment.HTMLURL)
+	return nil
+}
+
+// previewComment returns the markdown of the preview comment.
+func previewComment(rendered []string, diags []changelog.Diagnostic) string {
+	var b strings.Builder
+	b.WriteString(previewCommentMarker + "\n")
+	b.WriteString("### Release note preview\n\n")
+
+	if len(rendered) == 0 {
+		b.WriteString("This PR will not add anything to the changelog.\n")
+	}
+	for _, text := range rendered {
+		fmt.Fprintf(&b, "* %s\n", text)
+	}
+
+	if len(diags) > 0 {
+		b.WriteString("\n#### Warnings\n\n")
+		for _, d := range diags {
+			fmt.Fprintf(&b, "* %s\n", d)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+// defaultReleaseBodyTemplate lists the notes without headings, as the
+// release already has a title.
+const defaultReleaseBodyTemplate = `{{range .Notes -}}
+* {{renderReleaseNote .}}
+{{end -}}
+{{with .CompareURL}}
+**Full Changelog**: {{.}}
+{{end -}}`
+
+type publishCommand struct {
+	gh          githubFlags
+	notes       noteFlags
+	versions    versionFlags
+	cache       cacheFlags
+	state       stateFlags
+	templates   templateFlags
+	rest        restFlags
+	releaseBody string
+	tag         string
+	name        string
+	target      string
+	draft       bool
+}
+
+func (c *publishCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.versions.register(flagset)
+	c.cache.register(flagset)
+	c.state.register(flagset)
+	c.templates.register(flagset, false)
+	c.rest.register(flagset)
+	flagset.StringVar(&c.releaseBody,
+		"release-body",
+		"",
+		"Release body template path, the model is the same as the changelog template (leave blank for built-in template)",
+	)
+	flagset.StringVar(&c.tag,
+		"tag",
+		"",
+		"Tag of the release (defaults to -version or the suggested next version)",
+	)
+	flagset.StringVar(&c.name,
+		"name",
+		"",
+		"Name of the release (defaults to the tag)",
+	)
+	flagset.StringVar(&c.target,
+		"target",
+		"",
+		"Commit SHA or branch to create the tag from if it does not exist (defaults to the repository's default branch)",
+	)
+	flagset.BoolVar(&c.draft,
+		"draft",
+		false,
+		"Publish the release as a draft",
+	)
+}
+
+func (c *publishCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	// fail before querying GitHub if there is no way to name the release
+	if c.tag == "" && opts.Version == "" && opts.CurrentVersion == "" {
+		return errors.New("a tag is required, set -tag, -version or -current-version")
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.State, err = c.state.load()
+	if err != nil {
+		return err
+	}
+
+	_, opts.ReleaseNoteTemplate, err = c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	releaseBodyTemplate, err := loadTemplate(c.releaseBody)
+	if err != nil {
+		return fmt.Errorf("error loading release body template: %w", err)
+	}
+	if releaseBodyTemplate == "" {
+		releaseBodyTemplate = defaultReleaseBodyTemplate
+	}
+
+	client, restClient, logUsage, err := c.rest.clients(ctx, logger, &c.gh)
+	if err != nil {
+		return err
+	}
+	defer logUsage()
+
+	start, end, err := rangeArgs(ctx, client, c.gh.owner, c.gh.repo, args)
+	if err != nil {
+		return err
+	}
+	opts.StartRef, opts.EndRef = commitRef(args[0]), commitRef(args[1])
+
+	tag := c.tag
+	if tag != "" && opts.Version == "" {
+		opts.Version = tag
+	}
+
+	data, err := changelog.BuildChangelogData(ctx, client, logger, opts, start, end)
+	if err != nil {
+		return err
+	}
+
+	if tag == "" {
+		tag = data.Version
+	}
+	if tag == "" {
+		return errors.New("a tag is required, set -tag, -version or -current-version")
+	}
+
+	body, err := changelog.RenderChangelog(releaseBodyTemplate, opts.ReleaseNoteTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	name := c.name
+	if name == "" {
+		name = tag
+	}
+
+	release, err := changelog.PublishRelease(ctx, restClient, logger, c.gh.owner, c.gh.repo, changelog.Release{
+		TagName:         tag,
+		TargetCommitish: c.target,
+		Name:            name,
+		Body:            body,
+		Draft:           c.draft,
+		Prerelease:      changelog.IsPrerelease(tag),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.state.save(opts.State); err != nil {
+		return err
+	}
+
+	fmt.Println(release.HTMLURL)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+type renderCommand struct {
+	versions  versionFlags
+	templates templateFlags
+}
+
+func (c *renderCommand) register(flagset *flag.FlagSet) {
+	c.versions.register(flagset)
+	c.templates.register(flagset, true)
+}
+
+func (c *renderCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	filename := "-"
+	switch len(args) {
+	case 0:
+	case 1:
+		filename = args[0]
+	default:
+		return errors.New("at most 1 argument is allowed")
+	}
+
+	changelogTemplate, releaseNoteTemplate, err := c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	content, err := loadBody(filename)
+	if err != nil {
+		return fmt.Errorf("error reading release notes: %w", err)
+	}
+
+	var notes []changelog.ReleaseNote
+	if err := json.Unmarshal([]byte(content), &notes); err != nil {
+		return fmt.Errorf("error parsing release notes: %w", err)
+	}
+
+	var opts changelog.Options
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	data := changelog.ChangelogData{
+		Notes:     notes,
+		Version:   opts.Version,
+		Generated: time.Now().UTC(),
+	}
+	if opts.CurrentVersion != "" {
+		bump := changelog.SuggestBump(notes, opts.BumpRules)
+		data.NextVersion, err = changelog.NextVersion(opts.CurrentVersion, bump, opts.Prerelease)
+		if err != nil {
+			return err
+		}
+	}
+	if data.Version == "" {
+		data.Version = data.NextVersion
+	}
+
+	cl, err := changelog.RenderChangelog(changelogTemplate, releaseNoteTemplate, data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(cl)
+	return nil
+}
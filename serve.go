@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+
+	"github.com/paultyng/changelog-gen/changelog"
+)
+
+type serveCommand struct {
+	gh        githubFlags
+	notes     noteFlags
+	versions  versionFlags
+	cache     cacheFlags
+	templates templateFlags
+	listen    string
+	secret    string
+	refresh   time.Duration
+}
+
+func (c *serveCommand) register(flagset *flag.FlagSet) {
+	c.gh.register(flagset)
+	c.notes.register(flagset)
+	c.versions.register(flagset)
+	c.cache.register(flagset)
+	c.templates.register(flagset, true)
+	flagset.StringVar(&c.listen,
+		"listen",
+		envString("CHANGELOG_GEN_LISTEN", ":8080"),
+		"Address to listen on",
+	)
+	flagset.StringVar(&c.secret,
+		"webhook-secret",
+		envString("GITHUB_WEBHOOK_SECRET", ""),
+		"Secret used to verify webhook deliveries (required)",
+	)
+	flagset.DurationVar(&c.refresh,
+		"refresh",
+		0,
+		"Interval to recollect all unreleased changes at, in case webhook deliveries are missed (0 disables)",
+	)
+}
+
+func (c *serveCommand) run(ctx context.Context, logger hclog.Logger, args []string) error {
+	if err := c.gh.validate(); err != nil {
+		return err
+	}
+	if c.secret == "" {
+		return errors.New("webhook secret must be set via -webhook-secret or $GITHUB_WEBHOOK_SECRET")
+	}
+	if len(args) != 1 {
+		return errors.New("a start commit or RFC3339 timestamp is required")
+	}
+
+	opts, err := c.notes.options(&c.gh)
+	if err != nil {
+		return err
+	}
+
+	if err := c.versions.apply(&opts); err != nil {
+		return err
+	}
+
+	opts.Cache, err = c.cache.cache()
+	if err != nil {
+		return err
+	}
+
+	opts.ChangelogTemplate, opts.ReleaseNoteTemplate, err = c.templates.load()
+	if err != nil {
+		return err
+	}
+
+	client, logUsage := c.gh.client(ctx, logger)
+	defer logUsage()
+
+	start, err := resolveTime(ctx, client, c.gh.owner, c.gh.repo, args[0])
+	if err != nil {
+		return err
+	}
+	opts.StartRef = commitRef(args[0])
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	unreleased := changelog.NewUnreleased(client, logger, opts, start)
+	if err := unreleased.Refresh(ctx); err != nil {
+		return err
+	}
+
+	if c.refresh > 0 {
+		go func() {
+			ticker := time.NewTicker(c.refresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := unreleased.Refresh(ctx); err != nil {
+						logger.Error("error refreshing unreleased changes", "err", err)
+					}
+				}
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:    c.listen,
+		Handler: changelog.NewWebhookHandler(unreleased, []byte(c.secret)),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", c.listen)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}